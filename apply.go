@@ -0,0 +1,200 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ApplyOptions controls how ApplyPatchWithOptions applies a patch. By default the patch is applied
+// to the working tree only, like ApplyPatch.
+type ApplyOptions struct {
+	// ThreeWay falls back to a 3-way merge using the blobs recorded in the patch when it doesn't
+	// apply cleanly, leaving conflicts to resolve (`--3way`)
+	ThreeWay bool
+	// Index applies the patch to both the index and the working tree (`--index`)
+	Index bool
+	// Cached applies the patch to the index only, without touching the working tree (`--cached`)
+	Cached bool
+	// Check only reports whether the patch would apply, without applying it (`--check`)
+	Check bool
+	// Reverse applies the patch in reverse (`--reverse`)
+	Reverse bool
+	// Reject applies the hunks that can be applied and leaves the rest in .rej files, instead of
+	// applying nothing when any hunk fails (`--reject`)
+	Reject bool
+	// Include only applies changes to paths matching these globs (`--include`)
+	Include []string
+	// Exclude doesn't apply changes to paths matching these globs (`--exclude`)
+	Exclude []string
+}
+
+// ApplyFileStatus is the outcome of applying a patch to one file.
+type ApplyFileStatus int
+
+const (
+	// ApplyClean means the file's changes applied (or, with Check, would apply) cleanly
+	ApplyClean ApplyFileStatus = iota
+	// ApplyConflicted means a 3-way merge left conflicts in the file
+	ApplyConflicted
+	// ApplyRejected means some of the file's hunks were rejected and written to a .rej file
+	ApplyRejected
+	// ApplyFailed means the file's changes couldn't be applied
+	ApplyFailed
+	// ApplySkipped means the file was excluded by Include or Exclude
+	ApplySkipped
+)
+
+func (s ApplyFileStatus) String() string {
+	switch s {
+	case ApplyClean:
+		return "clean"
+	case ApplyConflicted:
+		return "conflicted"
+	case ApplyRejected:
+		return "rejected"
+	case ApplyFailed:
+		return "failed"
+	case ApplySkipped:
+		return "skipped"
+	default:
+		return fmt.Sprintf("ApplyFileStatus(%d)", int(s))
+	}
+}
+
+// ApplyFileResult is the result of applying a patch to one file.
+type ApplyFileResult struct {
+	Path   string
+	Status ApplyFileStatus
+	// FailedAt are the line numbers in the original file of hunks that didn't apply
+	FailedAt []int
+	// RejectedHunks are the 1-based numbers of the hunks written to the .rej file
+	RejectedHunks []int
+}
+
+// ApplyResult is the result of ApplyPatchWithOptions, with one entry per file in the patch. Unless
+// Reject or ThreeWay is set, git applies all or nothing, so when any file fails nothing is applied,
+// even to files reported as ApplyClean.
+type ApplyResult struct {
+	Files []ApplyFileResult
+}
+
+var (
+	applyChecking    = regexp.MustCompile(`^Checking patch (.*)\.\.\.$`)
+	applyMerged      = regexp.MustCompile(`^Applied patch to '(.*)' cleanly\.$`)
+	applyConflicts   = regexp.MustCompile(`^Applied patch to '(.*)' with conflicts\.$`)
+	applyFailed      = regexp.MustCompile(`^error: patch failed: (.*):(\d+)$`)
+	applyDoesntApply = regexp.MustCompile(`^error: (.*): (patch does not apply|does not exist in index|already exists in (working directory|index))$`)
+	applyRejecting   = regexp.MustCompile(`^Applying patch (.*) with \d+ rejects?\.\.\.$`)
+	applyRejected    = regexp.MustCompile(`^Rejected hunk #(\d+)\.$`)
+	applySkipped     = regexp.MustCompile(`^Skipped patch '(.*)'\.$`)
+)
+
+// ApplyPatchWithOptions applies the patch read from r according to opts, reporting the outcome for
+// each file. If the patch doesn't apply, the result is returned along with the error. If a 3-way
+// merge leaves conflicts, the error is a *ConflictError.
+func ApplyPatchWithOptions(r io.Reader, opts ApplyOptions) (*ApplyResult, error) {
+	return applyPatch(r, opts)
+}
+
+// applyPatch implements ApplyPatchWithOptions, adding env to git's environment.
+func applyPatch(r io.Reader, opts ApplyOptions, env ...string) (*ApplyResult, error) {
+	// we use --recount instead of trying to manually fix patch chunks ourselves
+	arg := []string{"apply", "--verbose", "--recount"}
+	if opts.ThreeWay {
+		arg = append(arg, "--3way")
+	}
+	if opts.Index {
+		arg = append(arg, "--index")
+	}
+	if opts.Cached {
+		arg = append(arg, "--cached")
+	}
+	if opts.Check {
+		arg = append(arg, "--check")
+	}
+	if opts.Reverse {
+		arg = append(arg, "--reverse")
+	}
+	if opts.Reject {
+		arg = append(arg, "--reject")
+	}
+	for _, include := range opts.Include {
+		arg = append(arg, "--include", include)
+	}
+	for _, exclude := range opts.Exclude {
+		arg = append(arg, "--exclude", exclude)
+	}
+	arg = append(arg, "-")
+
+	cmd := GitCmd(arg...)
+	// an IndexSession's env points git at its own index, which isn't part of the repository
+	cmd.mutates = !opts.Check && len(env) == 0
+	cmd.Stdin = r
+	// the per-file results are parsed from git's messages, so they mustn't be translated
+	cmd.Env = append(append(os.Environ(), "LC_ALL=C"), env...)
+
+	output, runErr := cmd.CombinedOutput()
+	result := parseApplyOutput(string(output))
+	if runErr == nil {
+		return result, nil
+	}
+
+	_, err := cmd.FormatOutput(output, runErr)
+	conflicted := []string{}
+	for _, file := range result.Files {
+		if file.Status == ApplyConflicted {
+			conflicted = append(conflicted, file.Path)
+		}
+	}
+	if len(conflicted) > 0 {
+		return result, &ConflictError{Op: "apply", Paths: conflicted, Err: err}
+	}
+	return result, err
+}
+
+// parseApplyOutput builds per-file results from the messages of `git apply --verbose`.
+func parseApplyOutput(output string) *ApplyResult {
+	result := &ApplyResult{Files: []ApplyFileResult{}}
+	file := func(path string) *ApplyFileResult {
+		for i := range result.Files {
+			if result.Files[i].Path == path {
+				return &result.Files[i]
+			}
+		}
+		result.Files = append(result.Files, ApplyFileResult{Path: path, Status: ApplyClean})
+		return &result.Files[len(result.Files)-1]
+	}
+
+	rejecting := ""
+	for _, line := range strings.Split(output, "\n") {
+		if match := applyChecking.FindStringSubmatch(line); match != nil {
+			file(match[1])
+		} else if match := applyMerged.FindStringSubmatch(line); match != nil {
+			// a 3-way merge fixed up a patch that failed to apply
+			file(match[1]).Status = ApplyClean
+		} else if match := applyConflicts.FindStringSubmatch(line); match != nil {
+			file(match[1]).Status = ApplyConflicted
+		} else if match := applyFailed.FindStringSubmatch(line); match != nil {
+			f := file(match[1])
+			f.Status = ApplyFailed
+			lineNumber, _ := strconv.Atoi(match[2])
+			f.FailedAt = append(f.FailedAt, lineNumber)
+		} else if match := applyDoesntApply.FindStringSubmatch(line); match != nil {
+			file(match[1]).Status = ApplyFailed
+		} else if match := applyRejecting.FindStringSubmatch(line); match != nil {
+			rejecting = match[1]
+			file(rejecting).Status = ApplyRejected
+		} else if match := applyRejected.FindStringSubmatch(line); match != nil && rejecting != "" {
+			f := file(rejecting)
+			hunk, _ := strconv.Atoi(match[1])
+			f.RejectedHunks = append(f.RejectedHunks, hunk)
+		} else if match := applySkipped.FindStringSubmatch(line); match != nil {
+			file(match[1]).Status = ApplySkipped
+		}
+	}
+	return result
+}
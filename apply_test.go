@@ -0,0 +1,142 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setupDriftedPatch commits numbered lines to P and Q, and returns a patch changing the last line
+// of both. Q's last line is then changed on HEAD so that the patch no longer applies to it.
+func setupDriftedPatch(t *testing.T) string {
+	if err := commitFileContent("P", numberedLines(10, "10")); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Q", numberedLines(10, "10")); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("P", []byte(numberedLines(10, "ten")), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("Q", []byte(numberedLines(10, "ten")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patch, err := GitOutput("diff")
+	if err != nil {
+		t.Fatal(err)
+	} else if err := Git("checkout", "--", "."); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Q", numberedLines(10, "TEN")); err != nil {
+		t.Fatal(err)
+	}
+	return patch + "\n"
+}
+
+func findApplyFileResult(t *testing.T, result *ApplyResult, path string) ApplyFileResult {
+	for _, file := range result.Files {
+		if file.Path == path {
+			return file
+		}
+	}
+	t.Fatal("No result for", path)
+	return ApplyFileResult{}
+}
+
+func TestApplyPatchWithOptionsCached(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	patch := setupDriftedPatch(t)
+	opts := ApplyOptions{Cached: true, Include: []string{"P"}}
+	if result, err := ApplyPatchWithOptions(strings.NewReader(patch), opts); err != nil {
+		t.Fatal(err)
+	} else if output, err := GitOutput("status", "-s"); err != nil {
+		t.Fatal(err)
+	} else if staged, err := ReadBlob(":P"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, ApplyClean, findApplyFileResult(t, result, "P").Status)
+		expectEq(t, ApplySkipped, findApplyFileResult(t, result, "Q").Status)
+		// staged without touching the working tree
+		expectEq(t, "MM P", output)
+		expectEq(t, numberedLines(10, "ten"), string(staged))
+	}
+}
+
+func TestApplyPatchWithOptionsCheck(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	patch := setupDriftedPatch(t)
+	if result, err := ApplyPatchWithOptions(strings.NewReader(patch), ApplyOptions{Check: true}); err == nil {
+		t.Fatal("Expected the patch not to apply")
+	} else if hasChanges, err := HasChanges(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, hasChanges)
+		expectEq(t, ApplyClean, findApplyFileResult(t, result, "P").Status)
+		failed := findApplyFileResult(t, result, "Q")
+		expectEq(t, ApplyFailed, failed.Status)
+		expectEq(t, 1, len(failed.FailedAt))
+	}
+
+	opts := ApplyOptions{Check: true, Exclude: []string{"Q"}}
+	if _, err := ApplyPatchWithOptions(strings.NewReader(patch), opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPatchWithOptionsReject(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	patch := setupDriftedPatch(t)
+	if result, err := ApplyPatchWithOptions(strings.NewReader(patch), ApplyOptions{Reject: true}); err == nil {
+		t.Fatal("Expected the patch to partially apply")
+	} else if bs, err := os.ReadFile("P"); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("Q.rej"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, numberedLines(10, "ten"), string(bs))
+		expectEq(t, ApplyClean, findApplyFileResult(t, result, "P").Status)
+		rejected := findApplyFileResult(t, result, "Q")
+		expectEq(t, ApplyRejected, rejected.Status)
+		expectEq(t, 1, len(rejected.RejectedHunks))
+		expectEq(t, 1, rejected.RejectedHunks[0])
+	}
+}
+
+func TestApplyPatchWithOptionsThreeWay(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	patch := setupDriftedPatch(t)
+	var conflictErr *ConflictError
+	if result, err := ApplyPatchWithOptions(strings.NewReader(patch), ApplyOptions{ThreeWay: true}); !errors.As(err, &conflictErr) {
+		t.Fatal("Expected a conflict, got", err)
+	} else if conflicts, err := Conflicts(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, ApplyClean, findApplyFileResult(t, result, "P").Status)
+		expectEq(t, ApplyConflicted, findApplyFileResult(t, result, "Q").Status)
+		expectEq(t, "Q", conflictErr.Paths[0])
+		expectEq(t, 1, len(conflicts))
+	}
+}
+
+func TestApplyPatchWithOptionsReverse(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := commitFileContent("P", numberedLines(10, "ten")); err != nil {
+		t.Fatal(err)
+	} else if patch, err := Diff("HEAD~1", "HEAD"); err != nil {
+		t.Fatal(err)
+	} else if _, err := ApplyPatchWithOptions(patch, ApplyOptions{Reverse: true, Index: true}); err != nil {
+		t.Fatal(err)
+	} else if output, err := GitOutput("status", "-s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "D  P", output)
+	}
+}
@@ -0,0 +1,185 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LineRange is an inclusive, 1-based range of lines.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// BlameOptions controls how Blame attributes lines.
+type BlameOptions struct {
+	// Ranges limits the blame to these line ranges (`-L`). If empty, the whole file is blamed.
+	Ranges []LineRange
+	// IgnoreWhitespace ignores whitespace changes when attributing lines (`-w`)
+	IgnoreWhitespace bool
+	// DetectMoves attributes lines moved within the file to where they came from (`-M`)
+	DetectMoves bool
+	// DetectCopies is the number of `-C` flags, from 0 to 3, each of which searches further for
+	// lines copied from other files
+	DetectCopies int
+	// IgnoreRevsFile is a file of commits to skip over, like `.git-blame-ignore-revs`
+	IgnoreRevsFile string
+	// IgnoreRevs are commits to skip over
+	IgnoreRevs []string
+}
+
+// BlameLine is the commit that last changed one line of a file.
+type BlameLine struct {
+	Hash string
+	// OrigLine and OrigPath are the line number and path in Hash
+	OrigLine int
+	OrigPath string
+	// FinalLine is the line number in the blamed revision of the file
+	FinalLine     int
+	Author        string
+	AuthorMail    string
+	AuthorTime    time.Time
+	Committer     string
+	CommitterMail string
+	CommitterTime time.Time
+	Summary       string
+	// Boundary is set for lines attributed to the boundary commit of a limited blame
+	Boundary bool
+	Content  string
+}
+
+// Blame attributes each line of path at rev to the commit that last changed it, by parsing
+// `git blame --porcelain`. If rev is "", the working tree version of the file is blamed.
+func Blame(path, rev string, opts BlameOptions) ([]BlameLine, error) {
+	arg := []string{"blame", "--porcelain"}
+	for _, r := range opts.Ranges {
+		arg = append(arg, "-L", fmt.Sprintf("%d,%d", r.Start, r.End))
+	}
+	if opts.IgnoreWhitespace {
+		arg = append(arg, "-w")
+	}
+	if opts.DetectMoves {
+		arg = append(arg, "-M")
+	}
+	for i := 0; i < opts.DetectCopies; i++ {
+		arg = append(arg, "-C")
+	}
+	if opts.IgnoreRevsFile != "" {
+		arg = append(arg, "--ignore-revs-file", opts.IgnoreRevsFile)
+	}
+	for _, ignoreRev := range opts.IgnoreRevs {
+		arg = append(arg, "--ignore-rev", ignoreRev)
+	}
+	if rev != "" {
+		arg = append(arg, rev)
+	}
+	arg = append(arg, "--", path)
+
+	output, err := gitRawOutput(arg...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlamePorcelain(string(output))
+}
+
+// parseBlamePorcelain parses `git blame --porcelain` output. Commit details are only printed the
+// first time a commit appears, so they're remembered for later lines.
+func parseBlamePorcelain(output string) ([]BlameLine, error) {
+	commits := map[string]*BlameLine{}
+	lines := []BlameLine{}
+
+	var current *BlameLine
+	var line BlameLine
+	for _, text := range strings.Split(output, "\n") {
+		if strings.HasPrefix(text, "\t") {
+			if current == nil {
+				return nil, fmt.Errorf("unexpected blame content before header: %q", text)
+			}
+			line.Content = text[1:]
+			lines = append(lines, line)
+			current = nil
+			continue
+		} else if text == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(text, " ")
+		if current == nil {
+			// "<hash> <orig line> <final line> [<lines in group>]"
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("unexpected blame header: %q", text)
+			}
+			if commits[fields[0]] == nil {
+				commits[fields[0]] = &BlameLine{Hash: fields[0]}
+			}
+			current = commits[fields[0]]
+			line = *current
+			line.OrigLine, _ = strconv.Atoi(fields[1])
+			line.FinalLine, _ = strconv.Atoi(fields[2])
+			continue
+		}
+
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-mail":
+			current.AuthorMail = strings.Trim(value, "<>")
+		case "author-time":
+			current.AuthorTime = parseUnixTime(value, current.AuthorTime)
+		case "author-tz":
+			current.AuthorTime = inGitZone(current.AuthorTime, value)
+		case "committer":
+			current.Committer = value
+		case "committer-mail":
+			current.CommitterMail = strings.Trim(value, "<>")
+		case "committer-time":
+			current.CommitterTime = parseUnixTime(value, current.CommitterTime)
+		case "committer-tz":
+			current.CommitterTime = inGitZone(current.CommitterTime, value)
+		case "summary":
+			current.Summary = value
+		case "boundary":
+			current.Boundary = true
+		case "filename":
+			current.OrigPath = value
+		}
+
+		// keep the per-line fields while picking up the commit's details
+		origLine, finalLine := line.OrigLine, line.FinalLine
+		line = *current
+		line.OrigLine, line.FinalLine = origLine, finalLine
+	}
+	return lines, nil
+}
+
+func parseUnixTime(value string, fallback time.Time) time.Time {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fallback
+	} else {
+		return time.Unix(seconds, 0)
+	}
+}
+
+// inGitZone converts t to the "+hhmm" time zone git reports.
+func inGitZone(t time.Time, tz string) time.Time {
+	if len(tz) != 5 {
+		return t
+	}
+	hours, err := strconv.Atoi(tz[1:3])
+	if err != nil {
+		return t
+	}
+	minutes, err := strconv.Atoi(tz[3:5])
+	if err != nil {
+		return t
+	}
+
+	offset := hours*60*60 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return t.In(time.FixedZone(tz, offset))
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+const k_BlameAuthor = "Blame Author <blame.author@example.com>"
+
+// setupBlame commits P with three lines, then a commit by k_BlameAuthor changing the middle line.
+// It returns the hashes of the two commits.
+func setupBlame(t *testing.T) (string, string) {
+	if err := commitFileContent("P", "one\ntwo\nthree\n"); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("P", []byte("one\n2\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("P"); err != nil {
+		t.Fatal(err)
+	} else if err := Git("commit", "--author", k_BlameAuthor, "--date", "1112912053 +0200", "-m", "change two"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := RevParse("HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return first, second
+}
+
+func TestBlame(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	first, second := setupBlame(t)
+	lines, err := Blame("P", "HEAD", BlameOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEq(t, 3, len(lines))
+	for i, expected := range []string{"one", "2", "three"} {
+		expectEq(t, expected, lines[i].Content)
+		expectEq(t, i+1, lines[i].FinalLine)
+		expectEq(t, "P", lines[i].OrigPath)
+	}
+	expectEq(t, first, lines[0].Hash)
+	expectEq(t, first, lines[2].Hash)
+	expectEq(t, "file P", lines[2].Summary)
+
+	changed := lines[1]
+	expectEq(t, second, changed.Hash)
+	expectEq(t, 2, changed.OrigLine)
+	expectEq(t, "Blame Author", changed.Author)
+	expectEq(t, "blame.author@example.com", changed.AuthorMail)
+	expectEq(t, "change two", changed.Summary)
+	expectTrue(t, changed.AuthorTime.Equal(time.Unix(1112912053, 0)))
+	_, offset := changed.AuthorTime.Zone()
+	expectEq(t, 2*60*60, offset)
+}
+
+func TestBlameOptions(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	first, second := setupBlame(t)
+	if lines, err := Blame("P", "HEAD", BlameOptions{Ranges: []LineRange{{2, 3}}}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(lines))
+		expectEq(t, second, lines[0].Hash)
+		expectEq(t, 2, lines[0].FinalLine)
+		expectEq(t, first, lines[1].Hash)
+	}
+
+	// skip over a commit that only changes indentation
+	var reformat string
+	if err := commitFileContent("P", "one\n2\n  three\n"); err != nil {
+		t.Fatal(err)
+	} else if reformat, err = RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if lines, err := Blame("P", "HEAD", BlameOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, reformat, lines[2].Hash)
+	}
+
+	if lines, err := Blame("P", "HEAD", BlameOptions{IgnoreWhitespace: true}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, first, lines[2].Hash)
+	}
+
+	if lines, err := Blame("P", "HEAD", BlameOptions{IgnoreRevs: []string{reformat}}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, first, lines[2].Hash)
+		expectEq(t, second, lines[1].Hash)
+	}
+
+	// uncommitted changes are blamed on the all-zero commit
+	if err := os.WriteFile("P", []byte("one\n2\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if lines, err := Blame("P", "", BlameOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "0000000000000000000000000000000000000000", lines[2].Hash)
+		expectEq(t, second, lines[1].Hash)
+	}
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ChangeIDTrailer is the trailer key used to identify a change across amends and rebases.
+const ChangeIDTrailer = "Change-Id"
+
+// GenerateChangeIDs makes Commit, Amend, AmendNoEdit and AmendWithMessage add a Change-Id trailer to
+// the commit message when it doesn't already have one. It is off by default.
+var GenerateChangeIDs bool
+
+// NewChangeID generates a new Gerrit-style change ID: "I" followed by 40 random hex digits.
+func NewChangeID() (string, error) {
+	bs := make([]byte, 20)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return "I" + hex.EncodeToString(bs), nil
+}
+
+// AddChangeID adds a newly generated Change-Id trailer to message unless it already has one.
+func AddChangeID(message string) (string, error) {
+	if id, err := NewChangeID(); err != nil {
+		return "", err
+	} else {
+		opts := TrailerOptions{IfExists: IfExistsDoNothing, IfMissing: IfMissingAdd}
+		return AddTrailers(message, opts, Trailer{Key: ChangeIDTrailer, Value: id})
+	}
+}
+
+// GetChangeID gets the change ID of the specified ref, or "" if its message has none.
+func GetChangeID(ref string) (string, error) {
+	if m, err := GetRefMessage(ref); err != nil {
+		return "", err
+	} else if values := m.TrailerValues(ChangeIDTrailer); len(values) == 0 {
+		return "", nil
+	} else {
+		return values[len(values)-1], nil
+	}
+}
+
+// FindChangeIDs maps the change IDs of the commits in revisionRange (e.g. "main..topic") to the
+// hashes of the commits that currently carry them. If several commits share a change ID, the most
+// recent one wins.
+func FindChangeIDs(revisionRange string) (map[string]string, error) {
+	format := fmt.Sprintf("--format=%%H %%(trailers:key=%s,valueonly,separator=%%x2C)", ChangeIDTrailer)
+	output, err := Log(format, revisionRange, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		hash, ids, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if ids == "" {
+			continue
+		}
+		for _, id := range strings.Split(ids, ",") {
+			if _, found := changes[id]; !found {
+				changes[id] = hash
+			}
+		}
+	}
+	return changes, nil
+}
+
+// addChangeIDOrKeep adds a Change-Id trailer to message unless it already has one. The trailer is
+// changeID, e.g. the change ID of a commit being amended, or a newly generated one if it's "".
+func addChangeIDOrKeep(message, changeID string) (string, error) {
+	if changeID == "" {
+		return AddChangeID(message)
+	}
+	opts := TrailerOptions{IfExists: IfExistsDoNothing, IfMissing: IfMissingAdd}
+	return AddTrailers(message, opts, Trailer{Key: ChangeIDTrailer, Value: changeID})
+}
+
+// changeIDAmendArgs returns the `git commit --amend` arguments that keep HEAD's message, adding a
+// Change-Id trailer if GenerateChangeIDs is set and the message doesn't already have one. The
+// trailer is previousID if it's set, otherwise a new change ID.
+func changeIDAmendArgs(previousID string) ([]string, error) {
+	if !GenerateChangeIDs {
+		return []string{"--no-edit"}, nil
+	} else if id, err := GetChangeID("HEAD"); err != nil {
+		return nil, err
+	} else if id != "" {
+		return []string{"--no-edit"}, nil
+	} else if message, err := FormatShowRefDescription("HEAD", "%B"); err != nil {
+		return nil, err
+	} else if message, err = addChangeIDOrKeep(message, previousID); err != nil {
+		return nil, err
+	} else {
+		return []string{"-m", message}, nil
+	}
+}
@@ -0,0 +1,133 @@
+package git
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewChangeID(t *testing.T) {
+	if id, err := NewChangeID(); err != nil {
+		t.Fatal(err)
+	} else if !regexp.MustCompile("^I[0-9a-f]{40}$").MatchString(id) {
+		t.Fatal("Malformed change ID", id)
+	} else if id2, err := NewChangeID(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectNEq(t, id, id2)
+	}
+}
+
+func TestAddChangeID(t *testing.T) {
+	if message, err := AddChangeID("subject"); err != nil {
+		t.Fatal(err)
+	} else if m, err := ParseMessage(message); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(m.TrailerValues(ChangeIDTrailer)))
+	}
+
+	// an existing change ID is left alone
+	if message, err := AddChangeID(k_TrailerMessage); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, k_TrailerMessage, message)
+	}
+}
+
+func TestGenerateChangeIDs(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	GenerateChangeIDs = true
+	defer func() { GenerateChangeIDs = false }()
+
+	var changeID string
+	if err := touch("G"); err != nil {
+		t.Fatal(err)
+	} else if err := Add("G"); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("file G"); err != nil {
+		t.Fatal(err)
+	} else if changeID, err = GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectNEq(t, "", changeID)
+	}
+
+	// amending keeps the change ID
+	if err := AmendNoEdit(); err != nil {
+		t.Fatal(err)
+	} else if id, err := GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, changeID, id)
+	}
+
+	// a commit that predates change IDs gets one on amend
+	if err := Checkout(g_RefNames[2]); err != nil {
+		t.Fatal(err)
+	} else if err := AmendNoEdit(); err != nil {
+		t.Fatal(err)
+	} else if id, err := GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectNEq(t, "", id)
+		expectEq(t, k_CommitDescriptions[2], desc)
+	}
+
+	// as does replacing the message
+	var err error
+	if changeID, err = GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if err := AmendWithMessage("new message"); err != nil {
+		t.Fatal(err)
+	} else if id, err := GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, changeID, id)
+	}
+
+	// and deleting it in the editor
+	CommandRunner.(*ExecRunner).Env = []string{"GIT_EDITOR=sed -i /^Change-Id:/d"}
+	if err := Amend(); err != nil {
+		t.Fatal(err)
+	} else if id, err := GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, changeID, id)
+		expectEq(t, "new message", desc)
+	}
+}
+
+func TestFindChangeIDs(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	GenerateChangeIDs = true
+	defer func() { GenerateChangeIDs = false }()
+
+	var changeID string
+	if err := AmendNoEdit(); err != nil {
+		t.Fatal(err)
+	} else if changeID, err = GetChangeID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if err := appendToFile("F", "lorem ipsum"); err != nil {
+		t.Fatal(err)
+	} else if err := Add("F"); err != nil {
+		t.Fatal(err)
+	} else if err := AmendNoEdit(); err != nil {
+		t.Fatal(err)
+	} else if hash, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if changes, err := FindChangeIDs(g_RefNames[0] + "..HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		// commits without change IDs aren't included
+		expectEq(t, 1, len(changes))
+		expectEq(t, hash, changes[changeID])
+	}
+}
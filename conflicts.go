@@ -0,0 +1,125 @@
+package git
+
+import (
+	"fmt"
+	"os"
+)
+
+// ConflictVersion is one side of a conflicted path. Hash is "" if the path doesn't exist on that
+// side, e.g. because it was deleted.
+type ConflictVersion struct {
+	Mode string
+	Hash string
+}
+
+// Exists returns whether the path exists on this side of the conflict.
+func (v ConflictVersion) Exists() bool {
+	return v.Hash != ""
+}
+
+// Content reads the content of this side of the conflict.
+func (v ConflictVersion) Content() ([]byte, error) {
+	if !v.Exists() {
+		return nil, fmt.Errorf("path does not exist on this side of the conflict")
+	}
+	return ReadBlob(v.Hash)
+}
+
+// Conflict is a path with unresolved conflicts from an in-progress merge, rebase, cherry-pick,
+// revert or patch application. Ours is the side being merged into (HEAD), which during a rebase is
+// the branch being rebased onto; Theirs is the side being applied.
+type Conflict struct {
+	Path   string
+	Base   ConflictVersion
+	Ours   ConflictVersion
+	Theirs ConflictVersion
+}
+
+// Conflicts lists the paths with unresolved conflicts along with the base, ours and theirs
+// versions of each.
+func Conflicts() ([]Conflict, error) {
+	entries, err := ListUnmerged()
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []Conflict{}
+	for _, entry := range entries {
+		// entries are sorted by path, then stage
+		if len(conflicts) == 0 || conflicts[len(conflicts)-1].Path != entry.Path {
+			conflicts = append(conflicts, Conflict{Path: entry.Path})
+		}
+		conflict := &conflicts[len(conflicts)-1]
+
+		version := ConflictVersion{Mode: entry.Mode, Hash: entry.Hash}
+		switch entry.Stage {
+		case 1:
+			conflict.Base = version
+		case 2:
+			conflict.Ours = version
+		case 3:
+			conflict.Theirs = version
+		}
+	}
+	return conflicts, nil
+}
+
+// ReadBlob reads the content of the specified blob object.
+func ReadBlob(object string) ([]byte, error) {
+	return gitRawOutput("cat-file", "blob", object)
+}
+
+// ResolveConflict writes content to the conflicted path in the working tree and stages it as the
+// resolution.
+func ResolveConflict(path string, content []byte) error {
+	if DryRun {
+		// leave the working tree alone too, and only report the add
+		return Add(path)
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(workingPath(path)); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := os.WriteFile(workingPath(path), content, mode); err != nil {
+		return err
+	}
+	return Add(path)
+}
+
+// ResolveConflictOurs resolves the conflicted path by taking our version wholesale. If our side
+// deleted the path, it is deleted.
+func ResolveConflictOurs(path string) error {
+	return resolveConflictWith(path, "--ours", 2)
+}
+
+// ResolveConflictTheirs resolves the conflicted path by taking their version wholesale. If their
+// side deleted the path, it is deleted.
+func ResolveConflictTheirs(path string) error {
+	return resolveConflictWith(path, "--theirs", 3)
+}
+
+func resolveConflictWith(path, side string, stage int) error {
+	entries, err := ListUnmerged()
+	if err != nil {
+		return err
+	}
+
+	conflicted, exists := false, false
+	for _, entry := range entries {
+		if entry.Path == path {
+			conflicted = true
+			exists = exists || entry.Stage == stage
+		}
+	}
+
+	if !conflicted {
+		return fmt.Errorf("%s is not conflicted", path)
+	} else if !exists {
+		return mutatingGit("rm", "--quiet", "--", path)
+	} else if err := mutatingGit("checkout", side, "--", path); err != nil {
+		return err
+	}
+	return Add(path)
+}
@@ -0,0 +1,164 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+func setupMergeConflict(t *testing.T) {
+	setupConflictingBranch(t, "topic", "ours\n", "theirs\n")
+	if result, err := Merge([]string{"topic"}, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeConflicted, result.Outcome)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupMergeConflict(t)
+	conflicts, err := Conflicts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEq(t, 1, len(conflicts))
+
+	conflict := conflicts[0]
+	expectEq(t, "F", conflict.Path)
+	expectTrue(t, conflict.Base.Exists())
+	if base, err := conflict.Base.Content(); err != nil {
+		t.Fatal(err)
+	} else if ours, err := conflict.Ours.Content(); err != nil {
+		t.Fatal(err)
+	} else if theirs, err := conflict.Theirs.Content(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "", string(base))
+		expectEq(t, "ours\n", string(ours))
+		expectEq(t, "theirs\n", string(theirs))
+	}
+}
+
+func TestReadBlob(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	content := "no trailing whitespace is trimmed\n\n"
+	if err := commitFileContent("Z", content); err != nil {
+		t.Fatal(err)
+	} else if hash, err := RevParse("HEAD:Z"); err != nil {
+		t.Fatal(err)
+	} else if bs, err := ReadBlob(hash); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, content, string(bs))
+	}
+
+	if _, err := ReadBlob("HEAD"); err == nil {
+		t.Fatal("Expected error reading a commit as a blob")
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupMergeConflict(t)
+	if err := ResolveConflict("F", []byte("resolved\n")); err != nil {
+		t.Fatal(err)
+	} else if conflicts, err := Conflicts(); err != nil {
+		t.Fatal(err)
+	} else if staged, err := ReadBlob(":F"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(conflicts))
+		expectEq(t, "resolved\n", string(staged))
+	}
+}
+
+func TestResolveConflictOurs(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupMergeConflict(t)
+	if err := ResolveConflictOurs("F"); err != nil {
+		t.Fatal(err)
+	} else if conflicts, err := Conflicts(); err != nil {
+		t.Fatal(err)
+	} else if bs, err := os.ReadFile("F"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(conflicts))
+		expectEq(t, "ours\n", string(bs))
+	}
+}
+
+func TestResolveConflictNotConflicted(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := ResolveConflictOurs("C"); err == nil {
+		t.Fatal("Expected an error for a path that isn't conflicted")
+	} else if err := ResolveConflictTheirs("C"); err == nil {
+		t.Fatal("Expected an error for a path that isn't conflicted")
+	} else if hasChanges, err := HasChanges(); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("C"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, hasChanges)
+	}
+}
+
+func TestResolveConflictTheirs(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupMergeConflict(t)
+	if err := ResolveConflictTheirs("F"); err != nil {
+		t.Fatal(err)
+	} else if bs, err := os.ReadFile("F"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "theirs\n", string(bs))
+	}
+
+	// a side that deleted the path resolves to deleting it
+	configDefaultBranchName, err := getConfigDefaultBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Commit("merge topic"); err != nil {
+		t.Fatal(err)
+	} else if err := CreateAndSwitchToBranch("deleted"); err != nil {
+		t.Fatal(err)
+	} else if err := Git("rm", "--quiet", "F"); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("delete F"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(configDefaultBranchName); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("F", "modified\n"); err != nil {
+		t.Fatal(err)
+	} else if result, err := Merge([]string{"deleted"}, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	} else if conflicts, err := Conflicts(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeConflicted, result.Outcome)
+		expectEq(t, 1, len(conflicts))
+		expectFalse(t, conflicts[0].Theirs.Exists())
+	}
+
+	if err := ResolveConflictTheirs("F"); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("F"); !os.IsNotExist(err) {
+		t.Fatal("Expected F to be deleted, got", err)
+	} else if conflicts, err := Conflicts(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(conflicts))
+	}
+}
@@ -0,0 +1,207 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DiffOptions controls what DiffWithOptions and the diff summary functions compare and how.
+//
+// With neither From nor To, the working tree is compared to the index, or with Cached the index
+// is compared to HEAD. With only From, the working tree (or with Cached, the index) is compared to
+// From. With both, the two refs are compared.
+type DiffOptions struct {
+	From   string
+	To     string
+	Cached bool
+	// Paths limits the diff to these pathspecs
+	Paths []string
+	// FindRenames detects renames (`-M`), with files at least RenameThreshold percent similar
+	// counting as renamed. A zero threshold uses git's default. Renames are only detected when
+	// FindRenames or FindCopies is set, regardless of git's diff.renames configuration.
+	FindRenames     bool
+	RenameThreshold int
+	// FindCopies detects copies as well as renames (`-C`), with files at least CopyThreshold
+	// percent similar counting as copied. A zero threshold uses git's default.
+	FindCopies    bool
+	CopyThreshold int
+	// Context is the number of context lines around each change (`-U`). Nil uses git's default.
+	Context *int
+	// IgnoreAllSpace ignores all whitespace when comparing lines (`-w`)
+	IgnoreAllSpace bool
+	// IgnoreSpaceChange ignores changes in the amount of whitespace (`-b`)
+	IgnoreSpaceChange bool
+	// IgnoreSpaceAtEOL ignores whitespace changes at the end of lines
+	IgnoreSpaceAtEOL bool
+	// IgnoreBlankLines ignores changes whose lines are all blank
+	IgnoreBlankLines bool
+}
+
+// args builds the `git diff` command line for opts with the specified output format flags.
+func (opts DiffOptions) args(format ...string) []string {
+	arg := append([]string{"diff", "--no-color", "--no-ext-diff"}, format...)
+	if opts.Cached {
+		arg = append(arg, "--cached")
+	}
+	if opts.FindRenames {
+		arg = append(arg, withPercent("-M", opts.RenameThreshold))
+	} else if !opts.FindCopies {
+		// don't let diff.renames turn it on behind our back
+		arg = append(arg, "--no-renames")
+	}
+	if opts.FindCopies {
+		arg = append(arg, withPercent("-C", opts.CopyThreshold))
+	}
+	if opts.Context != nil {
+		arg = append(arg, fmt.Sprintf("-U%d", *opts.Context))
+	}
+	if opts.IgnoreAllSpace {
+		arg = append(arg, "--ignore-all-space")
+	}
+	if opts.IgnoreSpaceChange {
+		arg = append(arg, "--ignore-space-change")
+	}
+	if opts.IgnoreSpaceAtEOL {
+		arg = append(arg, "--ignore-space-at-eol")
+	}
+	if opts.IgnoreBlankLines {
+		arg = append(arg, "--ignore-blank-lines")
+	}
+	if opts.From != "" {
+		arg = append(arg, opts.From)
+	}
+	if opts.To != "" {
+		arg = append(arg, opts.To)
+	}
+	return append(append(arg, "--"), opts.Paths...)
+}
+
+func withPercent(flag string, percent int) string {
+	if percent > 0 {
+		return fmt.Sprintf("%s%d%%", flag, percent)
+	}
+	return flag
+}
+
+// DiffWithOptions shows the diff/patch described by opts.
+func DiffWithOptions(opts DiffOptions) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	r, err := DiffStream(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = buf.ReadFrom(r)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// DiffStream streams the diff/patch described by opts without buffering it in memory. Close
+// returns git's error, including its error output, if it failed; closing the stream before
+// reading to the end stops git.
+func DiffStream(opts DiffOptions) (io.ReadCloser, error) {
+	return gitStream(opts.args("-p")...)
+}
+
+// DiffStat returns git's human-readable `--stat` summary of the diff described by opts.
+func DiffStat(opts DiffOptions) (string, error) {
+	return GitOutput(opts.args("--stat")...)
+}
+
+// NumStat is the number of lines added and deleted in one file.
+type NumStat struct {
+	Path string
+	// OldPath is the path before a rename or copy, or "" if there wasn't one
+	OldPath string
+	Added   int
+	Deleted int
+	// Binary files don't have line counts
+	Binary bool
+}
+
+// DiffNumStat returns the number of lines added and deleted in each file in the diff described by
+// opts (`--numstat`).
+func DiffNumStat(opts DiffOptions) ([]NumStat, error) {
+	output, err := gitRawOutput(opts.args("--numstat", "-z")...)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []NumStat{}
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		counts := strings.SplitN(fields[i], "\t", 3)
+		if len(counts) != 3 {
+			continue
+		}
+
+		stat := NumStat{Path: counts[2]}
+		if counts[0] == "-" && counts[1] == "-" {
+			stat.Binary = true
+		} else if stat.Added, err = strconv.Atoi(counts[0]); err != nil {
+			return nil, fmt.Errorf("unexpected numstat entry: %q", fields[i])
+		} else if stat.Deleted, err = strconv.Atoi(counts[1]); err != nil {
+			return nil, fmt.Errorf("unexpected numstat entry: %q", fields[i])
+		}
+
+		// renames and copies have an empty path followed by the old and new paths
+		if stat.Path == "" {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("unexpected numstat entry: %q", fields[i])
+			}
+			stat.OldPath, stat.Path = fields[i+1], fields[i+2]
+			i += 2
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// NameStatus is the kind of change made to one file.
+type NameStatus struct {
+	// Status is git's status letter: A (added), C (copied), D (deleted), M (modified), R (renamed),
+	// T (type changed) or U (unmerged)
+	Status byte
+	// Score is the similarity percentage of renames and copies
+	Score int
+	Path  string
+	// OldPath is the path before a rename or copy, or "" if there wasn't one
+	OldPath string
+}
+
+// DiffNameStatus returns the kind of change made to each file in the diff described by opts
+// (`--name-status`).
+func DiffNameStatus(opts DiffOptions) ([]NameStatus, error) {
+	output, err := gitRawOutput(opts.args("--name-status", "-z")...)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []NameStatus{}
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "" {
+			continue
+		}
+
+		status := NameStatus{Status: fields[i][0], Path: fields[i+1]}
+		if status.Status == 'R' || status.Status == 'C' {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("unexpected name-status entry: %q", fields[i])
+			}
+			status.Score, _ = strconv.Atoi(fields[i][1:])
+			status.OldPath, status.Path = fields[i+1], fields[i+2]
+			i++
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,183 @@
+package git
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setupRenamedFile commits P, then stages renaming it to R with its last line changed, a binary
+// file and the deletion of B.
+func setupRenamedFile(t *testing.T) {
+	if err := commitFileContent("P", numberedLines(20, "20")); err != nil {
+		t.Fatal(err)
+	} else if err := Git("mv", "P", "R"); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("R", []byte(numberedLines(20, "twenty")), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("bin", []byte{0, 1, 2, 3}, 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Git("rm", "--quiet", "B"); err != nil {
+		t.Fatal(err)
+	} else if err := Add("R", "bin"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffWithOptions(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	opts := DiffOptions{From: g_RefNames[0], To: g_RefNames[len(g_RefNames)-1], Paths: []string{"B", "C"}}
+	if patch, err := DiffWithOptions(opts); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, `diff --git a/B b/B
+new file mode 100644
+index 0000000..e69de29
+diff --git a/C b/C
+new file mode 100644
+index 0000000..e69de29
+`, patch.String())
+	}
+
+	// only staged changes show up with Cached, and only unstaged ones without
+	if err := commitFileContent("P", numberedLines(20, "20")); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("P", []byte(numberedLines(20, "twenty")), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("P"); err != nil {
+		t.Fatal(err)
+	} else if unstaged, err := DiffWithOptions(DiffOptions{}); err != nil {
+		t.Fatal(err)
+	} else if staged, err := DiffWithOptions(DiffOptions{Cached: true}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "", unstaged.String())
+		expectTrue(t, strings.Contains(staged.String(), "+twenty\n"))
+		expectTrue(t, strings.Contains(staged.String(), "\n 17\n"))
+	}
+
+	context := 0
+	if patch, err := DiffWithOptions(DiffOptions{From: "HEAD", Context: &context}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, strings.Contains(patch.String(), "@@ -20 +20 @@\n-20\n+twenty\n"))
+	}
+}
+
+func TestDiffNumStat(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupRenamedFile(t)
+	stats, err := DiffNumStat(DiffOptions{Cached: true, FindRenames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEq(t, 3, len(stats))
+	expectEq(t, NumStat{Path: "B"}, stats[0])
+	expectEq(t, NumStat{Path: "R", OldPath: "P", Added: 1, Deleted: 1}, stats[1])
+	expectEq(t, NumStat{Path: "bin", Binary: true}, stats[2])
+
+	// without rename detection, it's a deletion and an addition
+	if stats, err := DiffNumStat(DiffOptions{Cached: true, Paths: []string{"P", "R"}}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(stats))
+		expectEq(t, NumStat{Path: "P", Deleted: 20}, stats[0])
+		expectEq(t, NumStat{Path: "R", Added: 20}, stats[1])
+	}
+}
+
+func TestDiffNameStatus(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupRenamedFile(t)
+	statuses, err := DiffNameStatus(DiffOptions{From: "HEAD", Cached: true, FindRenames: true, RenameThreshold: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEq(t, 3, len(statuses))
+	expectEq(t, NameStatus{Status: 'D', Path: "B"}, statuses[0])
+	expectEq(t, byte('R'), statuses[1].Status)
+	expectEq(t, "P", statuses[1].OldPath)
+	expectEq(t, "R", statuses[1].Path)
+	expectTrue(t, statuses[1].Score >= 80)
+	expectEq(t, NameStatus{Status: 'A', Path: "bin"}, statuses[2])
+}
+
+func TestDiffStat(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupRenamedFile(t)
+	if stat, err := DiffStat(DiffOptions{Cached: true, FindRenames: true}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, strings.HasSuffix(stat, "3 files changed, 1 insertion(+), 1 deletion(-)"))
+	}
+}
+
+func TestDiffStream(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	opts := DiffOptions{From: g_RefNames[0], To: g_RefNames[len(g_RefNames)-1]}
+	if expected, err := DiffWithOptions(opts); err != nil {
+		t.Fatal(err)
+	} else if r, err := DiffStream(opts); err != nil {
+		t.Fatal(err)
+	} else if bs, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if err := r.Close(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, expected.String(), string(bs))
+	}
+
+	// stopping part-way isn't an error
+	if r, err := DiffStream(opts); err != nil {
+		t.Fatal(err)
+	} else if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	} else if err := r.Close(); err != nil {
+		t.Fatal(err)
+	} else if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// git's errors are reported on Close and never appear in the patch
+	if r, err := DiffStream(DiffOptions{From: "does-not-exist", To: "HEAD"}); err != nil {
+		t.Fatal(err)
+	} else if bs, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if err := r.Close(); err == nil {
+		t.Fatal("Expected an error for an invalid ref")
+	} else {
+		expectEq(t, "", string(bs))
+		expectTrue(t, strings.Contains(err.Error(), "does-not-exist"))
+	}
+
+	// even when the output isn't read
+	if r, err := DiffStream(DiffOptions{From: "does-not-exist", To: "HEAD"}); err != nil {
+		t.Fatal(err)
+	} else if err := r.Close(); err == nil {
+		t.Fatal("Expected an error for an invalid ref")
+	} else if err2 := r.Close(); err2 == nil {
+		t.Fatal("Expected closing again to return the same error")
+	} else {
+		expectTrue(t, strings.Contains(err.Error(), "does-not-exist"))
+		expectEq(t, err.Error(), err2.Error())
+	}
+
+	if buf, err := Diff("does-not-exist", "HEAD"); err == nil {
+		t.Fatal("Expected an error for an invalid ref")
+	} else {
+		expectEq(t, "", buf.String())
+	}
+}
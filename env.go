@@ -0,0 +1,101 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Environment controls the environment ExecRunner runs git in, to keep it from depending on the
+// user's configuration and locale. The zero value leaves git's environment alone.
+type Environment struct {
+	// IgnoreGlobalConfig ignores the user's ~/.gitconfig and $XDG_CONFIG_HOME/git/config, along with
+	// any aliases, hooks or pager settings they contain. Before git 2.32, which added
+	// GIT_CONFIG_GLOBAL, HOME and XDG_CONFIG_HOME are overridden instead.
+	IgnoreGlobalConfig bool
+	// IgnoreSystemConfig ignores the system-wide $(prefix)/etc/gitconfig
+	IgnoreSystemConfig bool
+	// NoTerminalPrompt makes git fail instead of prompting for credentials (GIT_TERMINAL_PROMPT=0)
+	NoTerminalPrompt bool
+	// CLocale makes git's messages untranslated, so they can be parsed (LC_ALL=C)
+	CLocale bool
+	// NoOptionalLocks stops read-only commands like `git status` from taking locks to refresh the
+	// index, so they don't conflict with other git processes (GIT_OPTIONAL_LOCKS=0)
+	NoOptionalLocks bool
+
+	// AuthorName, AuthorEmail, CommitterName and CommitterEmail override the identity recorded in
+	// new commits, if set
+	AuthorName     string
+	AuthorEmail    string
+	CommitterName  string
+	CommitterEmail string
+	// AuthorDate and CommitterDate override the dates recorded in new commits, if set. They can be
+	// in any format git accepts, e.g. "1112911993 +0000".
+	AuthorDate    string
+	CommitterDate string
+
+	// Config is passed to every invocation as `-c <key>=<value>`, overriding all config files
+	Config map[string]string
+}
+
+// HermeticEnvironment returns an Environment that ignores global and system config, never prompts
+// and uses the C locale. The identity and dates are left to be set as needed.
+func HermeticEnvironment() Environment {
+	return Environment{
+		IgnoreGlobalConfig: true,
+		IgnoreSystemConfig: true,
+		NoTerminalPrompt:   true,
+		CLocale:            true,
+		NoOptionalLocks:    true,
+	}
+}
+
+// args returns the `-c` options that go before the git command.
+func (e Environment) args() []string {
+	keys := make([]string, 0, len(e.Config))
+	for key := range e.Config {
+		keys = append(keys, key)
+	}
+	// sorted so that the same Environment always gives the same command line
+	sort.Strings(keys)
+
+	arg := []string{}
+	for _, key := range keys {
+		arg = append(arg, "-c", fmt.Sprintf("%s=%s", key, e.Config[key]))
+	}
+	return arg
+}
+
+// vars returns the environment variables to add to git's environment.
+func (e Environment) vars() []string {
+	vars := []string{}
+	if e.IgnoreGlobalConfig {
+		vars = append(vars, "GIT_CONFIG_GLOBAL="+os.DevNull)
+	}
+	if e.IgnoreSystemConfig {
+		vars = append(vars, "GIT_CONFIG_NOSYSTEM=1")
+	}
+	if e.NoTerminalPrompt {
+		vars = append(vars, "GIT_TERMINAL_PROMPT=0")
+	}
+	if e.CLocale {
+		vars = append(vars, "LC_ALL=C")
+	}
+	if e.NoOptionalLocks {
+		vars = append(vars, "GIT_OPTIONAL_LOCKS=0")
+	}
+
+	for _, v := range []struct{ name, value string }{
+		{"GIT_AUTHOR_NAME", e.AuthorName},
+		{"GIT_AUTHOR_EMAIL", e.AuthorEmail},
+		{"GIT_COMMITTER_NAME", e.CommitterName},
+		{"GIT_COMMITTER_EMAIL", e.CommitterEmail},
+		{"GIT_AUTHOR_DATE", e.AuthorDate},
+		{"GIT_COMMITTER_DATE", e.CommitterDate},
+	} {
+		if v.value != "" {
+			vars = append(vars, fmt.Sprintf("%s=%s", v.name, v.value))
+		}
+	}
+	return vars
+}
@@ -0,0 +1,78 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentArgs(t *testing.T) {
+	env := Environment{Config: map[string]string{"b.key": "2", "a.key": "1"}}
+	expectEq(t, "-c a.key=1 -c b.key=2", strings.Join(env.args(), " "))
+	expectEq(t, 0, len(env.vars()))
+
+	env = HermeticEnvironment()
+	env.AuthorName = "Author"
+	env.CommitterDate = "1112911993 +0000"
+	expectEq(t, strings.Join([]string{
+		"GIT_CONFIG_GLOBAL=" + os.DevNull,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_TERMINAL_PROMPT=0",
+		"LC_ALL=C",
+		"GIT_OPTIONAL_LOCKS=0",
+		"GIT_AUTHOR_NAME=Author",
+		"GIT_COMMITTER_DATE=1112911993 +0000",
+	}, " "), strings.Join(env.vars(), " "))
+}
+
+func TestHermeticEnvironment(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	// a global config with an alias that would change what `git log` means
+	global := filepath.Join(t.TempDir(), "gitconfig")
+	if err := os.WriteFile(global, []byte("[alias]\n\tstatus-alias = log\n[user]\n\tname = Global User\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+
+	if _, err := GitOutput("config", "--get", "alias.status-alias"); err == nil {
+		t.Fatal("Expected the global config to be ignored")
+	} else if name, err := GitOutput("config", "--get", "user.name"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, k_TestConfig["user.name"], name)
+	}
+
+	// without the Environment, the global config is used
+	plain := &ExecRunner{}
+	defer useRunner(plain)()
+	if alias, err := GitOutput("config", "--get", "alias.status-alias"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "log", alias)
+	}
+}
+
+func TestEnvironmentIdentity(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	env := HermeticEnvironment()
+	env.Config = k_TestConfig
+	env.AuthorName = "Pinned Author"
+	env.AuthorEmail = "pinned@example.com"
+	env.AuthorDate = "1112911993 +0000"
+	env.CommitterDate = "1112911993 +0000"
+	defer useRunner(&ExecRunner{Environment: env})()
+
+	if err := commitBlankFile("Z"); err != nil {
+		t.Fatal(err)
+	} else if identity, err := FormatShowRefDescription("HEAD", "%an <%ae> %at %ct %cn"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "Pinned Author <pinned@example.com> 1112911993 1112911993 "+k_TestConfig["user.name"], identity)
+	}
+
+}
@@ -0,0 +1,173 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// FormatPatchOptions controls how FormatPatch and FormatPatchFiles format patches.
+type FormatPatchOptions struct {
+	// CoverLetter adds a cover letter before the patches, as patch 0
+	CoverLetter bool
+	// CoverLetterSubject and CoverLetterBlurb replace git's placeholder subject and body in the
+	// cover letter
+	CoverLetterSubject string
+	CoverLetterBlurb   string
+	// SubjectPrefix replaces "PATCH" in the subject, e.g. "PATCH v2" (`--subject-prefix`)
+	SubjectPrefix string
+	// Numbered numbers the patches even when there is only one (`--numbered`)
+	Numbered bool
+	// Signoff adds a Signed-off-by trailer for the committer (`--signoff`)
+	Signoff bool
+}
+
+const (
+	k_CoverLetterSubjectPlaceholder = "*** SUBJECT HERE ***"
+	k_CoverLetterBlurbPlaceholder   = "*** BLURB HERE ***"
+)
+
+// FormatPatch returns the commits in revisionRange (e.g. "main..topic") as an mbox of patches,
+// oldest first, preserving their authorship and messages.
+func FormatPatch(revisionRange string, opts FormatPatchOptions) ([]byte, error) {
+	arg := append(formatPatchArgs(opts), "--stdout", revisionRange, "--")
+	if output, err := gitRawOutput(arg...); err != nil {
+		return nil, err
+	} else {
+		return fillCoverLetter(output, opts), nil
+	}
+}
+
+// FormatPatchFiles writes the commits in revisionRange to dir as one patch file per commit, and
+// returns the paths of the files in order.
+func FormatPatchFiles(revisionRange, dir string, opts FormatPatchOptions) ([]string, error) {
+	arg := append(formatPatchArgs(opts), "-o", dir, revisionRange, "--")
+	output, err := GitOutput(arg...)
+	if err != nil {
+		return nil, err
+	} else if output == "" {
+		return []string{}, nil
+	}
+
+	paths := strings.Split(output, "\n")
+	if opts.CoverLetter {
+		if bs, err := os.ReadFile(workingPath(paths[0])); err != nil {
+			return nil, err
+		} else if err := os.WriteFile(workingPath(paths[0]), fillCoverLetter(bs, opts), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func formatPatchArgs(opts FormatPatchOptions) []string {
+	arg := []string{"format-patch"}
+	if opts.CoverLetter {
+		arg = append(arg, "--cover-letter")
+	}
+	if opts.SubjectPrefix != "" {
+		arg = append(arg, "--subject-prefix", opts.SubjectPrefix)
+	}
+	if opts.Numbered {
+		arg = append(arg, "--numbered")
+	}
+	if opts.Signoff {
+		arg = append(arg, "--signoff")
+	}
+	return arg
+}
+
+// fillCoverLetter replaces the placeholders in the cover letter, which is always the first
+// message.
+func fillCoverLetter(mbox []byte, opts FormatPatchOptions) []byte {
+	if !opts.CoverLetter {
+		return mbox
+	}
+	if opts.CoverLetterSubject != "" {
+		mbox = bytes.Replace(mbox, []byte(k_CoverLetterSubjectPlaceholder), []byte(opts.CoverLetterSubject), 1)
+	}
+	if opts.CoverLetterBlurb != "" {
+		mbox = bytes.Replace(mbox, []byte(k_CoverLetterBlurbPlaceholder), []byte(opts.CoverLetterBlurb), 1)
+	}
+	return mbox
+}
+
+// AmEmptyMode is what Am does with patches that have no changes, such as cover letters.
+type AmEmptyMode string
+
+const (
+	// AmStopOnEmpty stops applying the series, as git does by default
+	AmStopOnEmpty AmEmptyMode = "stop"
+	// AmDropEmpty skips empty patches, e.g. to ignore a cover letter
+	AmDropEmpty AmEmptyMode = "drop"
+	// AmKeepEmpty records empty patches as empty commits
+	AmKeepEmpty AmEmptyMode = "keep"
+)
+
+// AmOptions controls how Am applies a patch series.
+type AmOptions struct {
+	// ThreeWay falls back to a 3-way merge when a patch doesn't apply cleanly (`--3way`)
+	ThreeWay bool
+	// Empty is what to do with empty patches. If empty, git's default is used.
+	Empty AmEmptyMode
+	// Signoff adds a Signed-off-by trailer for the committer (`--signoff`)
+	Signoff bool
+	// CommitterDateIsAuthorDate uses each patch's author date as the committer date
+	CommitterDateIsAuthorDate bool
+}
+
+// Am applies the series of patches in mbox, as created by FormatPatch, committing each one with its
+// original author, date and message. If it stops because a patch doesn't apply, it returns an
+// error, which is a *ConflictError if a 3-way merge left conflicts. Continue with AmContinue once
+// the patch is applied and staged, or use AmSkip or AmAbort.
+func Am(mbox io.Reader, opts AmOptions) error {
+	arg := []string{}
+	if opts.ThreeWay {
+		arg = append(arg, "--3way")
+	}
+	if opts.Empty != "" {
+		if err := requireCapability(CapabilityAmEmpty); err != nil {
+			return err
+		}
+		arg = append(arg, "--empty="+string(opts.Empty))
+	}
+	if opts.Signoff {
+		arg = append(arg, "--signoff")
+	}
+	if opts.CommitterDateIsAuthorDate {
+		arg = append(arg, "--committer-date-is-author-date")
+	}
+
+	return runSequencerWithInput("am", mbox, arg...)
+}
+
+// AmContinue commits the resolved patch that Am stopped on and carries on with the rest.
+func AmContinue() error {
+	return runSequencer("am", "--continue")
+}
+
+// AmSkip skips the patch that Am stopped on and carries on with the rest.
+func AmSkip() error {
+	return runSequencer("am", "--skip")
+}
+
+// AmAbort abandons an in-progress Am and restores the original branch.
+func AmAbort() error {
+	return mutatingGit("am", "--abort")
+}
+
+// IsAmInProgress returns whether an Am has stopped part-way through a series.
+func IsAmInProgress() (bool, error) {
+	path, err := gitPath("rebase-apply/applying")
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
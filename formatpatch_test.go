@@ -0,0 +1,157 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+const k_PatchAuthor = "Patch Author <patch.author@example.com>"
+
+// setupPatchSeries commits two changes by k_PatchAuthor on top of the fixture commits and returns
+// them as an mbox.
+func setupPatchSeries(t *testing.T, opts FormatPatchOptions) []byte {
+	if err := os.WriteFile("F", []byte("theirs\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("F"); err != nil {
+		t.Fatal(err)
+	} else if err := Git("commit", "--author", k_PatchAuthor, "-m", "change F"); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("Z"); err != nil {
+		t.Fatal(err)
+	}
+
+	mbox, err := FormatPatch("HEAD~2", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mbox
+}
+
+func TestFormatPatch(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	opts := FormatPatchOptions{
+		CoverLetter:        true,
+		CoverLetterSubject: "the series",
+		CoverLetterBlurb:   "what the series does",
+		SubjectPrefix:      "PATCH v2",
+	}
+	mbox := string(setupPatchSeries(t, opts))
+	expectTrue(t, strings.Contains(mbox, "Subject: [PATCH v2 0/2] the series\n"))
+	expectTrue(t, strings.Contains(mbox, "\nwhat the series does\n"))
+	expectTrue(t, strings.Contains(mbox, "Subject: [PATCH v2 1/2] change F\n"))
+	expectTrue(t, strings.Contains(mbox, "From: "+k_PatchAuthor+"\n"))
+	expectTrue(t, strings.Contains(mbox, "Subject: [PATCH v2 2/2] file Z\n"))
+}
+
+func TestFormatPatchFiles(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	opts := FormatPatchOptions{CoverLetter: true, CoverLetterSubject: "the series"}
+	if paths, err := FormatPatchFiles(g_RefNames[2]+"..HEAD", dir, opts); err != nil {
+		t.Fatal(err)
+	} else if cover, err := os.ReadFile(paths[0]); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 4, len(paths))
+		expectTrue(t, strings.HasPrefix(paths[0], dir))
+		expectTrue(t, strings.Contains(string(cover), "Subject: [PATCH 0/3] the series\n"))
+	}
+}
+
+func TestAm(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	mbox := setupPatchSeries(t, FormatPatchOptions{CoverLetter: true})
+
+	// apply the series in another repo with the same starting point
+	cleanupOther := setupGitRepo(t)
+	defer cleanupOther()
+
+	if err := Am(bytes.NewReader(mbox), AmOptions{Empty: AmDropEmpty}); err != nil {
+		t.Fatal(err)
+	} else if author, err := FormatShowRefDescription("HEAD~1", "%an <%ae>"); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD~1", "%s"); err != nil {
+		t.Fatal(err)
+	} else if bs, err := os.ReadFile("F"); err != nil {
+		t.Fatal(err)
+	} else if parent, err := RevParse("HEAD~2"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, k_PatchAuthor, author)
+		expectEq(t, "change F", desc)
+		expectEq(t, "theirs\n", string(bs))
+		expectEq(t, g_RefNames[len(g_RefNames)-1], parent)
+	}
+}
+
+func TestAmConflict(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	mbox := setupPatchSeries(t, FormatPatchOptions{})
+
+	cleanupOther := setupGitRepo(t)
+	defer cleanupOther()
+
+	var conflictErr *ConflictError
+	if err := commitFileContent("F", "ours\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Am(bytes.NewReader(mbox), AmOptions{ThreeWay: true}); !errors.As(err, &conflictErr) {
+		t.Fatal("Expected a conflict, got", err)
+	} else if inProgress, err := IsAmInProgress(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "F", conflictErr.Paths[0])
+		expectTrue(t, inProgress)
+	}
+
+	// resolve and carry on with the rest of the series
+	if err := ResolveConflictTheirs("F"); err != nil {
+		t.Fatal(err)
+	} else if err := AmContinue(); err != nil {
+		t.Fatal(err)
+	} else if inProgress, err := IsAmInProgress(); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("Z"); err != nil {
+		t.Fatal(err)
+	} else if author, err := FormatShowRefDescription("HEAD~1", "%an <%ae>"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, inProgress)
+		expectEq(t, k_PatchAuthor, author)
+	}
+}
+
+func TestAmAbort(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	mbox := setupPatchSeries(t, FormatPatchOptions{})
+
+	cleanupOther := setupGitRepo(t)
+	defer cleanupOther()
+
+	if err := commitFileContent("F", "ours\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Am(bytes.NewReader(mbox), AmOptions{}); err == nil {
+		t.Fatal("Expected the series not to apply")
+	} else if err := AmAbort(); err != nil {
+		t.Fatal(err)
+	} else if inProgress, err := IsAmInProgress(); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, inProgress)
+		expectEq(t, "file F", desc)
+	}
+}
@@ -0,0 +1,114 @@
+package gittest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Build makes the commits and branches described by spec, a list of statements separated by ";"
+// or newlines. Each statement is a chain of commit labels joined by "-", optionally preceded by
+// "<branch>:"; without a branch, the chain is on DefaultBranch. For example:
+//
+//	A-B-C; topic: B-D-M(C)
+//
+// makes commits A, B and C on DefaultBranch, then branch topic from B with D on top, then merges C
+// into topic as M.
+//
+// A new label is committed with Commit, or with Merge if it's followed by the labels to merge in
+// parentheses, separated by ",". A label that already exists moves the branch to it, so a chain on
+// a new branch starts from an existing label, and a chain on an existing branch without one
+// continues from its tip. HEAD is left on the branch that was checked out before, if it exists.
+// Labels can't contain "-", ",", ":", ";" or parentheses.
+func (r *Repo) Build(spec string) {
+	r.t.Helper()
+	if err := r.build(spec); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *Repo) build(spec string) error {
+	// HEAD is detached if this fails, and then there's no branch to go back to
+	original, _ := r.TryGit("symbolic-ref", "--quiet", "--short", "HEAD")
+
+	for _, stmt := range strings.FieldsFunc(spec, func(c rune) bool { return c == ';' || c == '\n' }) {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+
+		branch, chain, found := strings.Cut(stmt, ":")
+		if !found {
+			branch, chain = DefaultBranch, stmt
+		}
+		if err := r.buildChain(strings.TrimSpace(branch), strings.Split(chain, "-")); err != nil {
+			return fmt.Errorf("%q: %s", stmt, err)
+		}
+	}
+
+	if _, err := r.TryGit("rev-parse", "--verify", "--quiet", "refs/heads/"+original); original != "" && err == nil {
+		_, err = r.TryGit("checkout", "--quiet", original)
+		return err
+	}
+	return nil
+}
+
+func (r *Repo) buildChain(branch string, nodes []string) error {
+	// tip is the commit the next new label goes on top of, or "" for a root commit
+	tip := ""
+	if hash, err := r.TryGit("rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		tip = hash
+		if _, err := r.TryGit("checkout", "--quiet", branch); err != nil {
+			return err
+		}
+	} else if _, err := r.TryGit("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		// nothing has been committed yet, so the branch starts with a root commit
+		if _, err := r.TryGit("symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+			return err
+		}
+	} else if label, _, _ := parseNode(nodes[0]); r.labels[label] == "" {
+		return fmt.Errorf("new branch %s must start from an existing commit", branch)
+	}
+
+	for i, node := range nodes {
+		label, merged, err := parseNode(node)
+		if err != nil {
+			return err
+		}
+
+		if hash, exists := r.labels[label]; !exists {
+			if merged == nil {
+				tip = r.Commit(label)
+			} else {
+				tip = r.Merge(label, merged...)
+			}
+		} else if merged != nil {
+			return fmt.Errorf("%s already exists", label)
+		} else if parent, _ := r.TryGit("rev-parse", "--verify", "--quiet", hash+"^"); i > 0 && parent != tip {
+			return fmt.Errorf("%s isn't a child of the previous commit", label)
+		} else if _, err := r.TryGit("checkout", "--quiet", "-B", branch, hash); err != nil {
+			return err
+		} else {
+			tip = hash
+		}
+	}
+	return nil
+}
+
+// parseNode parses "<label>" or "<label>(<merged>,...)".
+func parseNode(node string) (string, []string, error) {
+	node = strings.TrimSpace(node)
+	label, rest, isMerge := strings.Cut(node, "(")
+	if label == "" {
+		return "", nil, fmt.Errorf("missing label in %q", node)
+	} else if !isMerge {
+		return label, nil, nil
+	} else if !strings.HasSuffix(rest, ")") {
+		return "", nil, fmt.Errorf("missing ) in %q", node)
+	}
+
+	merged := strings.Split(strings.TrimSuffix(rest, ")"), ",")
+	for i := range merged {
+		merged[i] = strings.TrimSpace(merged[i])
+	}
+	return label, merged, nil
+}
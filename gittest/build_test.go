@@ -0,0 +1,43 @@
+package gittest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	r := NewRepo(t)
+	r.Build("A-B-C; topic: B-D\nother: D-E-M(C)")
+
+	expectEq(t, r.Hash("C"), r.Hash(DefaultBranch))
+	expectEq(t, r.Hash("D"), r.Hash("topic"))
+	expectEq(t, r.Hash("M"), r.Hash("other"))
+	expectEq(t, r.Hash("B"), r.Git("rev-parse", r.Hash("D")+"~1"))
+	expectEq(t, r.Hash("E")+" "+r.Hash("C"), r.Git("log", "-1", "--format=%P", r.Hash("M")))
+	expectEq(t, "", r.Git("log", "-1", "--format=%P", r.Hash("A")))
+
+	// HEAD is left where it was, with a clean working tree
+	expectEq(t, DefaultBranch, r.Git("branch", "--show-current"))
+	expectEq(t, "", r.Git("status", "--porcelain"))
+
+	// chains on existing branches carry on from their tip
+	r.Build("topic: F")
+	expectEq(t, r.Hash("D"), r.Git("rev-parse", r.Hash("F")+"~1"))
+	expectEq(t, r.Hash("F"), r.Hash("topic"))
+}
+
+func TestBuildErrors(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"A-B; topic: C":   "must start from an existing commit",
+		"A-B; other: B-A": "isn't a child of the previous commit",
+		"A-B(A)-B(A)":     "already exists",
+		"A-B(A":           "missing )",
+	} {
+		r := NewRepo(t)
+		if err := r.build(spec); err == nil {
+			t.Fatal("Expected an error building", spec)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Fatal("Expected", expected, "building", spec, "actual error", err)
+		}
+	}
+}
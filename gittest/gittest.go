@@ -0,0 +1,227 @@
+// Package gittest provides git repositories for tests. Repositories live in t.TempDir(), git runs in
+// them without changing the working directory, commits get deterministic dates, and git's global
+// and system config are replaced so tests don't depend on the developer's setup.
+package gittest
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// DefaultBranch is the name of the branch new repositories start on.
+const DefaultBranch = "main"
+
+// AuthorName and AuthorEmail are the identity of the author and committer of every commit made in
+// a Repo.
+const (
+	AuthorName  = "A U Thor"
+	AuthorEmail = "author@example.com"
+)
+
+// firstCommitTime is the date of the first commit in a Repo, as seconds since the epoch. Each git
+// command run in the Repo moves the clock on by a minute.
+const firstCommitTime = 1112911993
+
+// Repo is a git repository in a temporary directory.
+type Repo struct {
+	// Dir is the working tree of the repository, or the repository itself if it's bare
+	Dir string
+
+	t      testing.TB
+	home   string
+	clock  *int
+	labels map[string]string
+}
+
+// NewRepo creates an empty repository on DefaultBranch with an isolated HOME and global config.
+func NewRepo(t testing.TB) *Repo {
+	t.Helper()
+	r := newRepo(t, newHome(t), new(int))
+	r.Git("init", "--quiet")
+	return r
+}
+
+// NewBareRepo creates an empty bare repository with an isolated HOME and global config, e.g. to push
+// to.
+func NewBareRepo(t testing.TB) *Repo {
+	t.Helper()
+	r := newRepo(t, newHome(t), new(int))
+	r.Git("init", "--quiet", "--bare")
+	return r
+}
+
+func newRepo(t testing.TB, home string, clock *int) *Repo {
+	return &Repo{Dir: t.TempDir(), t: t, home: home, clock: clock, labels: map[string]string{}}
+}
+
+// newHome creates a HOME directory with a global config that sets the identity and default branch.
+func newHome(t testing.TB) string {
+	t.Helper()
+	home := t.TempDir()
+	config := fmt.Sprintf(`[user]
+	name = %s
+	email = %s
+[init]
+	defaultBranch = %s
+[commit]
+	gpgSign = false
+[tag]
+	gpgSign = false
+`, AuthorName, AuthorEmail, DefaultBranch)
+
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return home
+}
+
+// Env returns the environment variables that isolate git from the developer's config, for
+// commands run outside the Repo's helpers.
+func (r *Repo) Env() []string {
+	return []string{
+		"HOME=" + r.home,
+		"XDG_CONFIG_HOME=" + filepath.Join(r.home, ".config"),
+		"GIT_CONFIG_GLOBAL=" + filepath.Join(r.home, ".gitconfig"),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_TERMINAL_PROMPT=0",
+	}
+}
+
+// Setenv sets the variables from Env in this process for the rest of the test, so that code
+// running git itself gets the same isolated config. Like t.Setenv, it can't be used in parallel
+// tests.
+func (r *Repo) Setenv() {
+	for _, v := range r.Env() {
+		key, value, _ := strings.Cut(v, "=")
+		r.t.Setenv(key, value)
+	}
+}
+
+// Git runs git in the repository and returns its trimmed output. The test fails if git does.
+func (r *Repo) Git(arg ...string) string {
+	r.t.Helper()
+	output, err := r.TryGit(arg...)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return output
+}
+
+// TryGit runs git in the repository and returns its trimmed output, or an error including the
+// output if git fails.
+func (r *Repo) TryGit(arg ...string) (string, error) {
+	date := fmt.Sprintf("%d +0000", firstCommitTime+*r.clock*60)
+	*r.clock++
+
+	cmd := exec.Command("git", arg...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(append(os.Environ(), r.Env()...), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s: %s\n%s", err, cmd.String(), output)
+	} else {
+		return strings.TrimSpace(string(output)), nil
+	}
+}
+
+// Path returns the path of a file in the repository.
+func (r *Repo) Path(name string) string {
+	return filepath.Join(r.Dir, filepath.FromSlash(name))
+}
+
+// WriteFile writes content to a file in the working tree, creating its directory if needed.
+func (r *Repo) WriteFile(name, content string) {
+	r.t.Helper()
+	path := r.Path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.t.Fatal(err)
+	} else if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// ReadFile reads a file in the working tree.
+func (r *Repo) ReadFile(name string) string {
+	r.t.Helper()
+	bs, err := os.ReadFile(r.Path(name))
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return string(bs)
+}
+
+// CommitFiles writes files to the working tree, stages them and commits them on the current branch
+// with message, returning the new commit's hash.
+func (r *Repo) CommitFiles(message string, files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		r.WriteFile(name, content)
+		r.Git("add", "--", name)
+	}
+	r.Git("commit", "--quiet", "--allow-empty", "-m", message)
+	return r.Git("rev-parse", "HEAD")
+}
+
+// Commit commits a file named label containing label on the current branch, with label as the
+// message, and remembers the commit as label for Hash and Build.
+func (r *Repo) Commit(label string) string {
+	r.t.Helper()
+	hash := r.CommitFiles(label, map[string]string{label: label + "\n"})
+	r.labels[label] = hash
+	return hash
+}
+
+// Hash returns the hash of the commit remembered as label, or else of label as a revision.
+func (r *Repo) Hash(label string) string {
+	r.t.Helper()
+	if hash, ok := r.labels[label]; ok {
+		return hash
+	}
+	return r.Git("rev-parse", "--verify", label+"^{commit}")
+}
+
+// Branch creates or moves branch to the commit label refers to.
+func (r *Repo) Branch(branch, label string) {
+	r.t.Helper()
+	r.Git("branch", "--force", branch, r.Hash(label))
+}
+
+// Checkout switches to branch.
+func (r *Repo) Checkout(branch string) {
+	r.t.Helper()
+	r.Git("checkout", "--quiet", branch)
+}
+
+// Tag creates an annotated tag at the commit label refers to.
+func (r *Repo) Tag(tag, label string) {
+	r.t.Helper()
+	r.Git("tag", "--annotate", "--message", tag, tag, r.Hash(label))
+}
+
+// Merge merges the commits others refer to into the current branch with a merge commit, which is
+// remembered as label.
+func (r *Repo) Merge(label string, others ...string) string {
+	r.t.Helper()
+	arg := []string{"merge", "--quiet", "--no-ff", "--no-edit", "-m", label}
+	for _, other := range others {
+		arg = append(arg, r.Hash(other))
+	}
+	r.Git(arg...)
+
+	hash := r.Git("rev-parse", "HEAD")
+	r.labels[label] = hash
+	return hash
+}
+
+// AddRemote creates a bare repository sharing this one's HOME and clock, and adds it as the remote
+// name.
+func (r *Repo) AddRemote(name string) *Repo {
+	r.t.Helper()
+	remote := newRepo(r.t, r.home, r.clock)
+	remote.Git("init", "--quiet", "--bare")
+	r.Git("remote", "add", name, remote.Dir)
+	return remote
+}
@@ -0,0 +1,84 @@
+package gittest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRepo(t *testing.T) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRepo(t)
+	a := r.Commit("A")
+	expectEq(t, DefaultBranch, r.Git("branch", "--show-current"))
+	expectEq(t, AuthorName+" <"+AuthorEmail+">", r.Git("log", "-1", "--format=%an <%ae>"))
+	expectEq(t, "A\n", r.ReadFile("A"))
+	expectEq(t, a, r.Hash("A"))
+	expectEq(t, a, r.Hash("HEAD"))
+
+	// the working directory isn't changed
+	if cwd, err := os.Getwd(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, pwd, cwd)
+	}
+
+	// the developer's config isn't used
+	origin := r.Git("config", "--show-origin", "--get", "user.name")
+	expectEq(t, true, strings.HasPrefix(origin, "file:"+filepath.Join(r.home, ".gitconfig")))
+}
+
+func TestDeterministicDates(t *testing.T) {
+	hashes := []string{}
+	for i := 0; i < 2; i++ {
+		r := NewRepo(t)
+		r.Commit("A")
+		hashes = append(hashes, r.CommitFiles("two files", map[string]string{"dir/B": "b\n", "C": "c\n"}))
+	}
+	expectEq(t, hashes[0], hashes[1])
+}
+
+func TestMergeAndTag(t *testing.T) {
+	r := NewRepo(t)
+	r.Commit("A")
+	r.Branch("topic", "A")
+	r.Commit("B")
+	r.Checkout("topic")
+	c := r.Commit("C")
+	r.Checkout(DefaultBranch)
+	m := r.Merge("M", "C")
+	r.Tag("v1", "M")
+
+	expectEq(t, r.Hash("B")+" "+c, r.Git("log", "-1", "--format=%P", m))
+	expectEq(t, m, r.Hash("v1"))
+	expectEq(t, "tag", r.Git("cat-file", "-t", "v1"))
+}
+
+func TestAddRemote(t *testing.T) {
+	r := NewRepo(t)
+	a := r.Commit("A")
+	remote := r.AddRemote("origin")
+	r.Git("push", "--quiet", "origin", DefaultBranch)
+
+	expectEq(t, a, remote.Hash(DefaultBranch))
+	expectEq(t, "true", remote.Git("rev-parse", "--is-bare-repository"))
+}
+
+func TestSetenv(t *testing.T) {
+	r := NewRepo(t)
+	r.Setenv()
+	expectEq(t, r.home, os.Getenv("HOME"))
+	expectEq(t, filepath.Join(r.home, ".gitconfig"), os.Getenv("GIT_CONFIG_GLOBAL"))
+}
+
+func expectEq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatal("Expected", expected, "actual value", actual)
+	}
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Reset unstages paths, resetting their index entries to HEAD without touching the working tree
+// (`git reset -- <paths>`).
+func Reset(paths ...string) error {
+	return mutatingGit(append([]string{"reset", "--quiet", "--"}, paths...)...)
+}
+
+// RestoreStaged resets the index entries of paths to their contents at source, or HEAD if source
+// is "", without touching the working tree (`git restore --staged`).
+func RestoreStaged(source string, paths ...string) error {
+	if supported, err := Supports(CapabilityRestore); err != nil {
+		return err
+	} else if !supported {
+		// older gits can do the same with reset
+		if source == "" {
+			source = "HEAD"
+		}
+		return mutatingGit(append([]string{"reset", "--quiet", source, "--"}, paths...)...)
+	}
+
+	arg := []string{"restore", "--staged"}
+	if source != "" {
+		arg = append(arg, "--source", source)
+	}
+	return mutatingGit(append(append(arg, "--"), paths...)...)
+}
+
+// AddIntentToAdd records that untracked paths will be added later (`git add --intent-to-add`), so
+// that they show up in diffs of the working tree and can be staged hunk by hunk.
+func AddIntentToAdd(paths ...string) error {
+	return mutatingGit(append([]string{"add", "--intent-to-add", "--"}, paths...)...)
+}
+
+// StagePatch applies the patch in r to the index only, e.g. to stage some of the hunks from a diff
+// of the working tree.
+func StagePatch(r io.Reader) error {
+	_, err := ApplyPatchWithOptions(r, ApplyOptions{Cached: true})
+	return err
+}
+
+// UpdateIndexCacheInfo stages the blob hash at path with the specified mode (e.g. "100644"),
+// whether or not path exists in the working tree (`git update-index --cacheinfo`).
+func UpdateIndexCacheInfo(mode, hash, path string) error {
+	return mutatingGit("update-index", "--add", "--cacheinfo", fmt.Sprintf("%s,%s,%s", mode, hash, path))
+}
+
+// ReadTree replaces the contents of an index with treeish (`git read-tree`). If indexFile is "",
+// the repository's index is used, otherwise indexFile is created or overwritten, leaving the
+// repository's index alone.
+func ReadTree(treeish, indexFile string) error {
+	cmd := GitCmd("read-tree", treeish)
+	cmd.Env = indexFileEnv(indexFile)
+	// a separate index file isn't part of the repository
+	cmd.mutates = indexFile == ""
+
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	return err
+}
+
+// ListIndex lists the entries of an index (`git ls-files --stage`). If indexFile is "", the
+// repository's index is listed.
+func ListIndex(indexFile string) ([]IndexEntry, error) {
+	cmd := GitCmd("ls-files", "--stage", "-z")
+	cmd.Env = indexFileEnv(indexFile)
+
+	if output, err := cmd.FormatOutput(cmd.CombinedOutput()); err != nil {
+		return nil, err
+	} else {
+		return parseIndexEntries(output)
+	}
+}
+
+// indexFileEnv returns the environment for a command that uses indexFile as its index, or nil (the
+// current environment) if indexFile is "".
+func indexFileEnv(indexFile string) []string {
+	if indexFile == "" {
+		return nil
+	}
+	return append(os.Environ(), fmt.Sprintf("GIT_INDEX_FILE=%s", indexFile))
+}
@@ -0,0 +1,154 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func findIndexEntry(entries []IndexEntry, path string) (IndexEntry, bool) {
+	for _, entry := range entries {
+		if entry.Path == path {
+			return entry, true
+		}
+	}
+	return IndexEntry{}, false
+}
+
+func TestReset(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := appendToFile("A", "a\n"); err != nil {
+		t.Fatal(err)
+	} else if err := appendToFile("B", "b\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Add("A", "B"); err != nil {
+		t.Fatal(err)
+	} else if err := Reset("A"); err != nil {
+		t.Fatal(err)
+	} else if staged, err := DiffNameStatus(DiffOptions{Cached: true}); err != nil {
+		t.Fatal(err)
+	} else if unstaged, err := DiffNameStatus(DiffOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(staged))
+		expectEq(t, "B", staged[0].Path)
+		expectEq(t, 1, len(unstaged))
+		expectEq(t, "A", unstaged[0].Path)
+	}
+
+	// the working tree is untouched
+	if err := RestoreStaged("", "B"); err != nil {
+		t.Fatal(err)
+	} else if staged, err := DiffNameStatus(DiffOptions{Cached: true}); err != nil {
+		t.Fatal(err)
+	} else if bs, err := os.ReadFile("B"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(staged))
+		expectEq(t, "b\n", string(bs))
+	}
+}
+
+func TestRestoreStagedSource(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	// stage the deletion of F as it was before it was added
+	if err := RestoreStaged(g_RefNames[0], "F"); err != nil {
+		t.Fatal(err)
+	} else if staged, err := DiffNameStatus(DiffOptions{Cached: true}); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("F"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, NameStatus{Status: 'D', Path: "F"}, staged[0])
+	}
+}
+
+func TestAddIntentToAdd(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := os.WriteFile("Z", []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := AddIntentToAdd("Z"); err != nil {
+		t.Fatal(err)
+	} else if patch, err := DiffWithOptions(DiffOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, strings.Contains(patch.String(), "+one\n+two\n"))
+	}
+
+	// stage only the first line
+	partial := `diff --git a/Z b/Z
+--- a/Z
++++ b/Z
+@@ -0,0 +1 @@
++one
+`
+	if err := StagePatch(strings.NewReader(partial)); err != nil {
+		t.Fatal(err)
+	} else if bs, err := ReadBlob(":Z"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "one\n", string(bs))
+	}
+}
+
+func TestUpdateIndexCacheInfo(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	hash, err := GitOutput("hash-object", "-w", "--stdin")
+	if err != nil {
+		t.Fatal(err)
+	} else if err := UpdateIndexCacheInfo("100755", hash, "bin/tool"); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, err := ListIndex(""); err != nil {
+		t.Fatal(err)
+	} else if entry, found := findIndexEntry(entries, "bin/tool"); !found {
+		t.Fatal("Expected bin/tool in the index")
+	} else {
+		expectEq(t, IndexEntry{Mode: "100755", Hash: hash, Stage: 0, Path: "bin/tool"}, entry)
+	}
+
+	if _, err := os.Stat("bin/tool"); !os.IsNotExist(err) {
+		t.Fatal("Expected bin/tool to only exist in the index")
+	}
+}
+
+func TestReadTree(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	indexFile := filepath.Join(t.TempDir(), "index")
+	if err := ReadTree(g_RefNames[1], indexFile); err != nil {
+		t.Fatal(err)
+	} else if entries, err := ListIndex(indexFile); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(entries))
+		expectEq(t, "A", entries[0].Path)
+		expectEq(t, "B", entries[1].Path)
+	}
+
+	// the repository's index is left alone
+	if entries, err := ListIndex(""); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, len(k_FileNames), len(entries))
+	}
+
+	if err := ReadTree(g_RefNames[0], ""); err != nil {
+		t.Fatal(err)
+	} else if entries, err := ListIndex(""); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(entries))
+	}
+}
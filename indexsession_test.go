@@ -0,0 +1,106 @@
+package git
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIndexSession(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	// the user's staging area and working tree must survive the session
+	if err := appendToFile("A", "staged\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Add("A"); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("Z", []byte("z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewIndexSession(g_RefNames[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := `diff --git a/B b/B
+--- a/B
++++ b/B
+@@ -0,0 +1 @@
++patched
+`
+	if err := s.Add("Z"); err != nil {
+		t.Fatal(err)
+	} else if err := s.Remove("A"); err != nil {
+		t.Fatal(err)
+	} else if err := s.ApplyPatch(strings.NewReader(patch)); err != nil {
+		t.Fatal(err)
+	} else if entries, err := s.List(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(entries))
+		expectEq(t, "B", entries[0].Path)
+		expectEq(t, "Z", entries[1].Path)
+	}
+
+	commit, err := s.Commit("synthesized", g_RefNames[1])
+	if err != nil {
+		t.Fatal(err)
+	} else if err := s.Close(); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(s.IndexFile()); !os.IsNotExist(err) {
+		t.Fatal("Expected the temporary index to be removed")
+	}
+
+	if bs, err := ReadFile(commit, "B"); err != nil {
+		t.Fatal(err)
+	} else if exists, err := Exists(commit, "A"); err != nil {
+		t.Fatal(err)
+	} else if parent, err := RevParse(commit + "~1"); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription(commit, "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "patched\n", string(bs))
+		expectFalse(t, exists)
+		expectEq(t, g_RefNames[1], parent)
+		expectEq(t, "synthesized", desc)
+	}
+
+	if head, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if staged, err := DiffNameStatus(DiffOptions{Cached: true}); err != nil {
+		t.Fatal(err)
+	} else if bs, err := os.ReadFile("B"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, g_RefNames[len(g_RefNames)-1], head)
+		expectEq(t, 1, len(staged))
+		expectEq(t, NameStatus{Status: 'M', Path: "A"}, staged[0])
+		expectEq(t, "", string(bs))
+	}
+}
+
+func TestIndexSessionEmpty(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	s, err := NewIndexSession("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if hash, err := GitOutput("hash-object", "-w", "A"); err != nil {
+		t.Fatal(err)
+	} else if err := s.UpdateCacheInfo("100644", hash, "dir/A"); err != nil {
+		t.Fatal(err)
+	} else if tree, err := s.WriteTree(); err != nil {
+		t.Fatal(err)
+	} else if entries, err := ListTree(tree, "", true); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(entries))
+		expectEq(t, "dir/A", entries[0].Path)
+	}
+}
@@ -0,0 +1,183 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JournalOperations makes Rebase, CreateBranchForced, ForceDeleteBranch, Amend, AmendWithMessage
+// and AmendNoEdit record the refs they're about to change in a journal in the git directory, so
+// that the change can be reverted with Undo. It is off by default. Only the most recent
+// maxJournalOperations are kept.
+var JournalOperations bool
+
+const journalFile = "go-git-utils-journal"
+const maxJournalOperations = 100
+
+// RefState is the value of a ref before an operation changed it.
+type RefState struct {
+	Ref string
+	// Hash is "" if the ref didn't exist
+	Hash string
+}
+
+// Operation is a journal entry for one call that changed refs.
+type Operation struct {
+	ID   string
+	Name string
+	Time time.Time
+	Refs []RefState
+}
+
+// Operations lists the operations in the journal, newest first.
+func Operations() ([]Operation, error) {
+	path, err := gitPath(journalFile)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(operations)-1; i < j; i, j = i+1, j-1 {
+		operations[i], operations[j] = operations[j], operations[i]
+	}
+	return operations, nil
+}
+
+// Undo restores the refs changed by the operation with the specified ID to their values before it
+// ran, in a single atomic ref update. Refs it created are deleted. If JournalOperations is set, the
+// undo is journaled too, so it can itself be undone.
+func Undo(operationID string) error {
+	operations, err := Operations()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range operations {
+		if op.ID != operationID {
+			continue
+		}
+
+		refs := make([]string, 0, len(op.Refs))
+		var sb strings.Builder
+		for _, state := range op.Refs {
+			refs = append(refs, state.Ref)
+			if state.Hash == "" {
+				fmt.Fprintf(&sb, "delete %s\n", state.Ref)
+			} else {
+				fmt.Fprintf(&sb, "update %s %s\n", state.Ref, state.Hash)
+			}
+		}
+		if err := journal("undo "+op.Name, refs...); err != nil {
+			return err
+		}
+
+		cmd := mutatingGitCmd("update-ref", "-m", fmt.Sprintf("undo %s", op.Name), "--no-deref", "--stdin")
+		cmd.Stdin = strings.NewReader(sb.String())
+		_, err := cmd.FormatOutput(cmd.CombinedOutput())
+		return err
+	}
+	return fmt.Errorf("no operation %q in the journal", operationID)
+}
+
+// journal records the current values of refs before the named operation changes them, if
+// JournalOperations is set and DryRun isn't. Branch names and "HEAD" are recorded as the full name of the ref they
+// refer to; anything else that isn't a ref, such as a commit hash, is skipped.
+func journal(name string, refs ...string) error {
+	if !JournalOperations || DryRun {
+		return nil
+	}
+
+	now := time.Now()
+	op := Operation{ID: fmt.Sprintf("%d", now.UnixNano()), Name: name, Time: now}
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref, "refs/") {
+			if fullName, err := GitOutput("rev-parse", "--symbolic-full-name", ref); err != nil {
+				return err
+			} else if fullName == "" {
+				continue
+			} else {
+				ref = fullName
+			}
+		}
+
+		if hash, err := refHash(ref); err != nil {
+			return err
+		} else {
+			op.Refs = append(op.Refs, RefState{Ref: ref, Hash: hash})
+		}
+	}
+
+	path, err := gitPath(journalFile)
+	if err != nil {
+		return err
+	}
+	operations, err := readJournal(path)
+	if err != nil {
+		return err
+	}
+	operations = append(operations, op)
+	if len(operations) > maxJournalOperations {
+		operations = operations[len(operations)-maxJournalOperations:]
+	}
+	return writeJournal(path, operations)
+}
+
+// refHash returns the hash ref points to, or "" if it doesn't exist.
+func refHash(ref string) (string, error) {
+	cmd := GitCmd("rev-parse", "--quiet", "--verify", ref)
+	output, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if cmd.ExitCode() == 0 {
+		return output, nil
+	} else if cmd.ExitCode() == 1 {
+		return "", nil
+	} else {
+		return "", err
+	}
+}
+
+// readJournal reads the journal, which has one JSON-encoded Operation per line, oldest first.
+func readJournal(path string) ([]Operation, error) {
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []Operation{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	operations := []Operation{}
+	for scanner := bufio.NewScanner(bytes.NewReader(bs)); scanner.Scan(); {
+		var op Operation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return nil, fmt.Errorf("unexpected journal entry: %q: %s", scanner.Text(), err)
+		}
+		operations = append(operations, op)
+	}
+	return operations, nil
+}
+
+func writeJournal(path string, operations []Operation) error {
+	buf := &bytes.Buffer{}
+	for _, op := range operations {
+		if bs, err := json.Marshal(op); err != nil {
+			return err
+		} else {
+			buf.Write(bs)
+			buf.WriteByte('\n')
+		}
+	}
+
+	// write the whole journal before replacing it, so an interrupted write can't truncate it
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	JournalOperations = true
+	defer func() { JournalOperations = false }()
+
+	if err := CreateBranchForced("topic", g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	} else if err := CreateBranchForced("topic", g_RefNames[1]); err != nil {
+		t.Fatal(err)
+	} else if err := AmendWithMessage("amended"); err != nil {
+		t.Fatal(err)
+	}
+
+	operations, err := Operations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch, err := GetCurrentBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEq(t, 3, len(operations))
+	expectEq(t, "commit --amend", operations[0].Name)
+	expectEq(t, 1, len(operations[0].Refs))
+	expectEq(t, RefState{Ref: "refs/heads/" + branch, Hash: g_RefNames[len(g_RefNames)-1]}, operations[0].Refs[0])
+	expectEq(t, RefState{Ref: "refs/heads/topic", Hash: g_RefNames[0]}, operations[1].Refs[0])
+	expectEq(t, RefState{Ref: "refs/heads/topic"}, operations[2].Refs[0])
+
+	if err := Undo(operations[0].ID); err != nil {
+		t.Fatal(err)
+	} else if head, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, g_RefNames[len(g_RefNames)-1], head)
+	}
+
+	if err := Undo(operations[1].ID); err != nil {
+		t.Fatal(err)
+	} else if topic, err := RevParse("topic"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, g_RefNames[0], topic)
+	}
+
+	// undoing the creation deletes the branch
+	if err := Undo(operations[2].ID); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, BranchExists("topic"))
+	}
+
+	// undos are journaled too
+	if operations, err := Operations(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 6, len(operations))
+		expectEq(t, "undo branch -f topic "+g_RefNames[0], operations[0].Name)
+		expectEq(t, RefState{Ref: "refs/heads/topic", Hash: g_RefNames[0]}, operations[0].Refs[0])
+	}
+
+	if err := Undo("does-not-exist"); err == nil {
+		t.Fatal("Expected an error for an unknown operation")
+	}
+}
+
+func TestJournalOperationsOff(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := CreateBranchForced("topic", g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	} else if err := Rebase(g_RefNames[1], "topic"); err != nil {
+		t.Fatal(err)
+	} else if operations, err := Operations(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(operations))
+	}
+}
@@ -0,0 +1,281 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FastForwardMode controls whether Merge may fast-forward.
+type FastForwardMode int
+
+const (
+	// FastForwardDefault follows git's merge.ff configuration
+	FastForwardDefault FastForwardMode = iota
+	// FastForwardIfPossible fast-forwards when possible and creates a merge commit otherwise (`--ff`)
+	FastForwardIfPossible
+	// FastForwardOnly fails rather than create a merge commit (`--ff-only`)
+	FastForwardOnly
+	// NoFastForward always creates a merge commit (`--no-ff`)
+	NoFastForward
+)
+
+// MergeOptions controls how Merge merges.
+type MergeOptions struct {
+	FastForward FastForwardMode
+	// Strategy is the merge strategy, e.g. "ort" or "ours" (`-s`)
+	Strategy string
+	// StrategyOptions are passed to the strategy, e.g. "theirs" or "ignore-space-change" (`-X`)
+	StrategyOptions []string
+	// Squash stages the merged changes without creating a merge commit. If Message is also set,
+	// the squashed changes are committed with it.
+	Squash bool
+	// Message is the merge commit message. If empty, git's default message is used.
+	Message string
+}
+
+// MergeOutcome describes what Merge did.
+type MergeOutcome int
+
+const (
+	// MergeUpToDate means there was nothing to merge
+	MergeUpToDate MergeOutcome = iota
+	// MergeFastForwarded means HEAD was fast-forwarded without creating a commit
+	MergeFastForwarded
+	// MergeCommitted means a merge commit was created
+	MergeCommitted
+	// MergeSquashed means the changes were squashed into the index, and committed if a message
+	// was given
+	MergeSquashed
+	// MergeConflicted means the merge stopped with conflicts
+	MergeConflicted
+)
+
+func (o MergeOutcome) String() string {
+	switch o {
+	case MergeUpToDate:
+		return "up to date"
+	case MergeFastForwarded:
+		return "fast-forwarded"
+	case MergeCommitted:
+		return "committed"
+	case MergeSquashed:
+		return "squashed"
+	case MergeConflicted:
+		return "conflicted"
+	default:
+		return fmt.Sprintf("MergeOutcome(%d)", int(o))
+	}
+}
+
+// MergeResult is the result of a Merge.
+type MergeResult struct {
+	Outcome MergeOutcome
+	// Head is the hash of HEAD after the merge
+	Head string
+	// Conflicts are the unmerged index entries when Outcome is MergeConflicted
+	Conflicts []IndexEntry
+}
+
+// IndexEntry is an entry in the index as listed by `git ls-files --stage`. Stage is 0 for normal
+// entries, and 1 (base), 2 (ours) or 3 (theirs) for unmerged entries.
+type IndexEntry struct {
+	Mode  string
+	Hash  string
+	Stage int
+	Path  string
+}
+
+// Merge merges refs into HEAD. Stopping with conflicts isn't an error; the result's Outcome is
+// MergeConflicted and Conflicts lists the unmerged entries. Resolve them and commit, or call
+// MergeAbort.
+func Merge(refs []string, opts MergeOptions) (*MergeResult, error) {
+	before, err := RevParse("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergedCommits(refs)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := []string{"merge"}
+	switch opts.FastForward {
+	case FastForwardIfPossible:
+		arg = append(arg, "--ff")
+	case FastForwardOnly:
+		arg = append(arg, "--ff-only")
+	case NoFastForward:
+		arg = append(arg, "--no-ff")
+	}
+	if opts.Strategy != "" {
+		arg = append(arg, "-s", opts.Strategy)
+	}
+	for _, option := range opts.StrategyOptions {
+		arg = append(arg, "-X", option)
+	}
+	if opts.Squash {
+		arg = append(arg, "--squash")
+	} else if opts.Message != "" {
+		arg = append(arg, "-m", opts.Message)
+	} else {
+		arg = append(arg, "--no-edit")
+	}
+	arg = append(arg, refs...)
+
+	if mergeErr := mutatingGit(arg...); mergeErr != nil {
+		if conflicts, err := ListUnmerged(); err != nil {
+			return nil, err
+		} else if len(conflicts) == 0 {
+			return nil, mergeErr
+		} else {
+			return &MergeResult{Outcome: MergeConflicted, Head: before, Conflicts: conflicts}, nil
+		}
+	}
+
+	if opts.Squash {
+		return squashResult(before, opts.Message)
+	}
+
+	after, err := RevParse("HEAD")
+	if err != nil {
+		return nil, err
+	} else if after == before {
+		return &MergeResult{Outcome: MergeUpToDate, Head: after}, nil
+	}
+
+	// a fast-forward moves straight to one of the merged commits, which may itself be a merge
+	if merged[after] {
+		return &MergeResult{Outcome: MergeFastForwarded, Head: after}, nil
+	}
+
+	// a new merge commit's parents are the old HEAD and the merged commits, less any git left out
+	// for already being reachable from the others
+	parents, err := GitOutput("rev-list", "--parents", "-n", "1", after)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(parents)
+	isMergeCommit := len(fields) > 2
+	for i := 1; isMergeCommit && i < len(fields); i++ {
+		isMergeCommit = fields[i] == before || merged[fields[i]]
+	}
+	if !isMergeCommit {
+		return nil, fmt.Errorf("unexpected result merging %s: HEAD is %s", strings.Join(refs, " "), after)
+	}
+	return &MergeResult{Outcome: MergeCommitted, Head: after}, nil
+}
+
+// mergedCommits returns the set of commits refs point to.
+func mergedCommits(refs []string) (map[string]bool, error) {
+	// --verify only takes one revision, but ^{commit} fails for anything that isn't a commit
+	arg := []string{"rev-parse"}
+	for _, ref := range refs {
+		arg = append(arg, ref+"^{commit}")
+	}
+	output, err := GitOutput(arg...)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := map[string]bool{}
+	for _, hash := range strings.Fields(output) {
+		merged[hash] = true
+	}
+	return merged, nil
+}
+
+// squashResult commits the staged result of a squash merge if there's a message, and reports it.
+func squashResult(before, message string) (*MergeResult, error) {
+	cmd := GitCmd("diff", "--cached", "--quiet")
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if cmd.ExitCode() == 0 {
+		return &MergeResult{Outcome: MergeUpToDate, Head: before}, nil
+	} else if cmd.ExitCode() != 1 {
+		return nil, err
+	}
+
+	if message != "" {
+		if err := Commit(message); err != nil {
+			return nil, err
+		}
+	}
+
+	if after, err := RevParse("HEAD"); err != nil {
+		return nil, err
+	} else {
+		return &MergeResult{Outcome: MergeSquashed, Head: after}, nil
+	}
+}
+
+// MergeAbort abandons an in-progress merge and restores the pre-merge state (`git merge --abort`).
+func MergeAbort() error {
+	return mutatingGit("merge", "--abort")
+}
+
+// MergeHeads returns the commits being merged into HEAD by an in-progress merge, as recorded in
+// MERGE_HEAD. It returns no commits if no merge is in progress.
+func MergeHeads() ([]string, error) {
+	path, err := gitPath("MERGE_HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(bs)), nil
+}
+
+// ListUnmerged lists the index entries of paths with unresolved conflicts (`git ls-files -u`).
+func ListUnmerged() ([]IndexEntry, error) {
+	if output, err := GitOutput("ls-files", "-u", "-z"); err != nil {
+		return nil, err
+	} else {
+		return parseIndexEntries(output)
+	}
+}
+
+// parseIndexEntries parses the NUL-terminated "<mode> <hash> <stage>\t<path>" entries printed by
+// `git ls-files --stage -z`.
+func parseIndexEntries(output string) ([]IndexEntry, error) {
+	entries := []IndexEntry{}
+	for _, record := range strings.Split(output, "\x00") {
+		if record == "" {
+			continue
+		}
+
+		info, path, found := strings.Cut(record, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected index entry: %q", record)
+		}
+		stage, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected index entry: %q", record)
+		}
+		entries = append(entries, IndexEntry{Mode: fields[0], Hash: fields[1], Stage: stage, Path: path})
+	}
+	return entries, nil
+}
+
+// gitPath returns the absolute path of a file in the git directory, e.g. "MERGE_HEAD".
+func gitPath(name string) (string, error) {
+	if supported, err := Supports(CapabilityPathFormat); err != nil {
+		return "", err
+	} else if supported {
+		return GitOutput("rev-parse", "--path-format=absolute", "--git-path", name)
+	}
+
+	// older gits give a path relative to the directory they run in
+	if path, err := GitOutput("rev-parse", "--git-path", name); err != nil {
+		return "", err
+	} else {
+		return filepath.Abs(workingPath(path))
+	}
+}
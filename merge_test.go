@@ -0,0 +1,184 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	configDefaultBranchName, err := getConfigDefaultBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var topicHash string
+	if err := CreateAndSwitchToBranch("topic"); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("Z"); err != nil {
+		t.Fatal(err)
+	} else if topicHash, err = RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(configDefaultBranchName); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, err := Merge([]string{"topic"}, MergeOptions{FastForward: NoFastForward, Message: "merge topic"}); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeCommitted, result.Outcome)
+		expectEq(t, "merge topic", desc)
+	}
+
+	if result, err := Merge([]string{"topic"}, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeUpToDate, result.Outcome)
+	}
+
+	// fast-forwarding onto an existing merge commit doesn't make a new one
+	if mergeHash, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if err := CreateBranchForced("behind", g_RefNames[len(g_RefNames)-1]); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout("behind"); err != nil {
+		t.Fatal(err)
+	} else if result, err := Merge([]string{configDefaultBranchName}, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeFastForwarded, result.Outcome)
+		expectEq(t, mergeHash, result.Head)
+	}
+
+	if err := CreateBranchForced(configDefaultBranchName+"-copy", g_RefNames[len(g_RefNames)-1]); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(configDefaultBranchName + "-copy"); err != nil {
+		t.Fatal(err)
+	} else if result, err := Merge([]string{"topic"}, MergeOptions{FastForward: FastForwardOnly}); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeFastForwarded, result.Outcome)
+		expectEq(t, topicHash, result.Head)
+	}
+
+	// diverged, so can't fast-forward
+	if err := Checkout(g_RefNames[3]); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("Y"); err != nil {
+		t.Fatal(err)
+	} else if _, err := Merge([]string{"topic"}, MergeOptions{FastForward: FastForwardOnly}); err == nil {
+		t.Fatal("Expected fast-forward only merge of diverged branches to fail")
+	}
+}
+
+func TestMergeSquash(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := CreateAndSwitchToBranch("topic"); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("Z"); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("Y"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(g_RefNames[len(g_RefNames)-1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, err := Merge([]string{"topic"}, MergeOptions{Squash: true}); err != nil {
+		t.Fatal(err)
+	} else if output, err := GitOutput("status", "-s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeSquashed, result.Outcome)
+		expectEq(t, g_RefNames[len(g_RefNames)-1], result.Head)
+		expectEq(t, "A  Y\nA  Z", output)
+	}
+
+	if err := Git("reset", "--hard"); err != nil {
+		t.Fatal(err)
+	} else if result, err := Merge([]string{"topic"}, MergeOptions{Squash: true, Message: "squash topic"}); err != nil {
+		t.Fatal(err)
+	} else if parent, err := RevParse("HEAD~1"); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeSquashed, result.Outcome)
+		expectEq(t, g_RefNames[len(g_RefNames)-1], parent)
+		expectEq(t, "squash topic", desc)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupConflictingBranch(t, "topic", "ours\n", "theirs\n")
+
+	if result, err := Merge([]string{"topic"}, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	} else if topicHash, err := RevParse("topic"); err != nil {
+		t.Fatal(err)
+	} else if heads, err := MergeHeads(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeConflicted, result.Outcome)
+		expectEq(t, 3, len(result.Conflicts))
+		for i, entry := range result.Conflicts {
+			expectEq(t, "F", entry.Path)
+			expectEq(t, i+1, entry.Stage)
+			expectEq(t, "100644", entry.Mode)
+		}
+		expectEq(t, 1, len(heads))
+		expectEq(t, topicHash, heads[0])
+	}
+
+	if err := MergeAbort(); err != nil {
+		t.Fatal(err)
+	} else if heads, err := MergeHeads(); err != nil {
+		t.Fatal(err)
+	} else if hasChanges, err := HasChanges(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(heads))
+		expectFalse(t, hasChanges)
+	}
+
+	// the same merge resolves cleanly when favoring their side
+	if result, err := Merge([]string{"topic"}, MergeOptions{StrategyOptions: []string{"theirs"}}); err != nil {
+		t.Fatal(err)
+	} else if bs, err := os.ReadFile("F"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, MergeCommitted, result.Outcome)
+		expectEq(t, "theirs\n", string(bs))
+	}
+}
+
+func TestListUnmerged(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if entries, err := ListUnmerged(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(entries))
+	}
+
+	setupConflictingBranch(t, "topic", "ours\n", "theirs\n")
+	if _, err := Merge([]string{"topic"}, MergeOptions{}); err != nil {
+		t.Fatal(err)
+	} else if entries, err := ListUnmerged(); err != nil {
+		t.Fatal(err)
+	} else if ours, err := RevParse(":2:F"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 3, len(entries))
+		expectEq(t, ours, entries[1].Hash)
+	}
+}
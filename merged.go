@@ -0,0 +1,195 @@
+package git
+
+import (
+	"fmt"
+)
+
+// BranchMergeStatus describes how much of a branch has landed in a target branch.
+type BranchMergeStatus int
+
+const (
+	// BranchUnmerged has none of its changes in the target
+	BranchUnmerged BranchMergeStatus = iota
+	// BranchPartiallyLanded has some, but not all, of its commits in the target under different
+	// hashes
+	BranchPartiallyLanded
+	// BranchSquashMerged has all of its changes in the target under different hashes, e.g. after a
+	// squash-merge or rebase-merge
+	BranchSquashMerged
+	// BranchMerged is an ancestor of the target
+	BranchMerged
+)
+
+func (s BranchMergeStatus) String() string {
+	switch s {
+	case BranchUnmerged:
+		return "unmerged"
+	case BranchPartiallyLanded:
+		return "partially landed"
+	case BranchSquashMerged:
+		return "squash-merged"
+	case BranchMerged:
+		return "merged"
+	default:
+		return fmt.Sprintf("BranchMergeStatus(%d)", int(s))
+	}
+}
+
+// BranchMergeState is the merge status of a local branch.
+type BranchMergeState struct {
+	Branch string
+	Status BranchMergeStatus
+}
+
+// MergedBranches classifies every local branch other than target by how much of it has landed in
+// target. Branches that are ancestors of target are merged. Otherwise a branch is squash-merged if
+// all of its commits have equivalent patches in target, if its whole diff from the merge base has
+// an equivalent patch in target, or if merging it into target wouldn't change target's tree.
+func MergedBranches(target string) ([]BranchMergeState, error) {
+	branches, err := ListBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	states := []BranchMergeState{}
+	for _, branch := range branches {
+		if branch == target {
+			continue
+		}
+		if status, err := GetBranchMergeStatus(branch, target); err != nil {
+			return nil, err
+		} else {
+			states = append(states, BranchMergeState{Branch: branch, Status: status})
+		}
+	}
+	return states, nil
+}
+
+// GetBranchMergeStatus classifies how much of branch has landed in target, as per MergedBranches.
+func GetBranchMergeStatus(branch, target string) (BranchMergeStatus, error) {
+	if isAncestor, err := IsAncestor(branch, target); err != nil {
+		return BranchUnmerged, err
+	} else if isAncestor {
+		return BranchMerged, nil
+	}
+
+	commits, err := Cherry(target, branch)
+	if err != nil {
+		return BranchUnmerged, err
+	}
+	landed := 0
+	for _, commit := range commits {
+		if commit.Upstream {
+			landed++
+		}
+	}
+	if landed == len(commits) {
+		return BranchSquashMerged, nil
+	}
+
+	mergeBase, err := GitOutput("merge-base", target, branch)
+	if err != nil {
+		// no common history, so nothing can have landed
+		return BranchUnmerged, nil
+	}
+
+	if squashed, err := isSquashLanded(mergeBase, branch, target); err != nil {
+		return BranchUnmerged, err
+	} else if squashed {
+		return BranchSquashMerged, nil
+	} else if unchanged, err := isMergeNoOp(branch, target); err != nil {
+		return BranchUnmerged, err
+	} else if unchanged {
+		return BranchSquashMerged, nil
+	} else if landed > 0 {
+		return BranchPartiallyLanded, nil
+	}
+	return BranchUnmerged, nil
+}
+
+// DeleteMergedBranches force-deletes the local branches that are merged or squash-merged into
+// target and returns them. The current branch is never deleted. If dryRun is set, nothing is
+// deleted and the branches that would have been are returned.
+func DeleteMergedBranches(target string, dryRun bool) ([]BranchMergeState, error) {
+	states, err := MergedBranches(target)
+	if err != nil {
+		return nil, err
+	}
+	current, err := GetCurrentBranchName()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []BranchMergeState{}
+	for _, state := range states {
+		if state.Branch == current {
+			continue
+		} else if state.Status != BranchMerged && state.Status != BranchSquashMerged {
+			continue
+		}
+
+		if !dryRun {
+			if err := ForceDeleteBranch(state.Branch); err != nil {
+				return deleted, err
+			}
+		}
+		deleted = append(deleted, state)
+	}
+	return deleted, nil
+}
+
+// isSquashLanded returns whether the whole diff from mergeBase to branch has the same patch ID as a
+// commit on target since mergeBase.
+func isSquashLanded(mergeBase, branch, target string) (bool, error) {
+	squashed, err := GitOutput("diff", "-p", "--no-color", mergeBase, branch)
+	if err != nil {
+		return false, err
+	} else if squashed == "" {
+		return false, nil
+	}
+
+	squashedIDs, err := patchIDs(squashed)
+	if err != nil {
+		return false, err
+	}
+	landed, err := GitOutput("log", "-p", "--no-color", "--no-merges", fmt.Sprintf("%s..%s", mergeBase, target))
+	if err != nil {
+		return false, err
+	} else if landed == "" {
+		return false, nil
+	}
+	landedIDs, err := patchIDs(landed)
+	if err != nil {
+		return false, err
+	}
+
+	for patchID := range squashedIDs {
+		if _, found := landedIDs[patchID]; found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isMergeNoOp returns whether merging branch into target would leave target's tree unchanged. Older
+// gits can't merge without a working tree, so it's never considered a no-op for them.
+func isMergeNoOp(branch, target string) (bool, error) {
+	if supported, err := Supports(CapabilityMergeTreeWriteTree); err != nil || !supported {
+		return false, err
+	}
+
+	cmd := GitCmd("merge-tree", "--write-tree", "--no-messages", target, branch)
+	output, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if cmd.ExitCode() == 1 {
+		// the merge has conflicts, so it would definitely change something
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	targetTree, err := RevParse(target + "^{tree}")
+	if err != nil {
+		return false, err
+	}
+	return output == targetTree, nil
+}
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+func setupMergedBranches(t *testing.T) string {
+	defaultBranchName := setupLandedBranch(t, "partial")
+
+	if err := CreateBranchForced("merged", g_RefNames[3]); err != nil {
+		t.Fatal(err)
+	} else if err := CreateAndSwitchToBranch("unmerged"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("U", "unmerged\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(defaultBranchName); err != nil {
+		t.Fatal(err)
+	} else if err := CreateAndSwitchToBranch("squashed"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("P", "squashed\n"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Q", "squashed\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(defaultBranchName); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("P", []byte("squashed\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("Q", []byte("squashed\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("P", "Q"); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("squash P and Q"); err != nil {
+		t.Fatal(err)
+	}
+	return defaultBranchName
+}
+
+func TestMergedBranches(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	defaultBranchName := setupMergedBranches(t)
+	expected := map[string]BranchMergeStatus{
+		"merged":   BranchMerged,
+		"squashed": BranchSquashMerged,
+		"partial":  BranchPartiallyLanded,
+		"unmerged": BranchUnmerged,
+	}
+
+	if states, err := MergedBranches(defaultBranchName); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, len(expected), len(states))
+		for _, state := range states {
+			expectEq(t, expected[state.Branch], state.Status)
+		}
+	}
+
+	// once the rest of the partially landed branch lands, the whole branch has landed
+	if err := os.WriteFile("Y", []byte("not landed\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("Y"); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("land Y"); err != nil {
+		t.Fatal(err)
+	} else if status, err := GetBranchMergeStatus("partial", defaultBranchName); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, BranchSquashMerged, status)
+	}
+}
+
+func TestDeleteMergedBranches(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	defaultBranchName := setupMergedBranches(t)
+
+	if deleted, err := DeleteMergedBranches(defaultBranchName, true); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(deleted))
+		expectTrue(t, BranchExists("merged"))
+		expectTrue(t, BranchExists("squashed"))
+	}
+
+	if deleted, err := DeleteMergedBranches(defaultBranchName, false); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(deleted))
+		expectFalse(t, BranchExists("merged"))
+		expectFalse(t, BranchExists("squashed"))
+		expectTrue(t, BranchExists("partial"))
+		expectTrue(t, BranchExists("unmerged"))
+	}
+}
@@ -0,0 +1,72 @@
+package git
+
+import (
+	"strings"
+)
+
+// CherryCommit is a commit reported by `git cherry`.
+type CherryCommit struct {
+	Hash string
+	// Upstream is true if an equivalent change (same patch ID) is already in upstream
+	Upstream bool
+}
+
+// PatchID returns the stable patch ID (`git patch-id --stable`) of the change introduced by commit,
+// or "" if the commit doesn't change anything. Commits with the same patch ID make the same change,
+// even if they have different hashes, e.g. after a rebase or cherry-pick.
+func PatchID(commit string) (string, error) {
+	patch, err := GitOutput("diff-tree", "-p", "--root", "--no-commit-id", "--no-color", commit)
+	if err != nil {
+		return "", err
+	} else if patch == "" {
+		return "", nil
+	}
+
+	if ids, err := patchIDs(patch); err != nil {
+		return "", err
+	} else {
+		// the commit ID is all 0s since the patch doesn't have a commit header
+		for patchID := range ids {
+			return patchID, nil
+		}
+		return "", nil
+	}
+}
+
+// patchIDs runs `git patch-id --stable` on patch, which may be the output of `git log -p`, and
+// maps each patch ID to the commit it was computed for.
+func patchIDs(patch string) (map[string]string, error) {
+	cmd := GitCmd("patch-id", "--stable")
+	cmd.Stdin = strings.NewReader(patch + "\n")
+
+	output, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		// each line is "<patch-id> <commit-id>"
+		if patchID, commit, found := strings.Cut(line, " "); found {
+			ids[patchID] = commit
+		}
+	}
+	return ids, nil
+}
+
+// Cherry lists the commits in head that aren't in upstream, oldest first, and whether an equivalent
+// change has already been applied to upstream under a different hash (`git cherry`).
+func Cherry(upstream, head string) ([]CherryCommit, error) {
+	output, err := GitOutput("cherry", upstream, head)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []CherryCommit{}
+	for _, line := range strings.Split(output, "\n") {
+		if sign, hash, found := strings.Cut(line, " "); found {
+			commits = append(commits, CherryCommit{Hash: hash, Upstream: sign == "-"})
+		}
+	}
+	return commits, nil
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+// setupLandedBranch creates a branch named topic from the default branch with two commits, and
+// lands an equivalent of the first one on the default branch under a different hash. It returns
+// the default branch name.
+func setupLandedBranch(t *testing.T, topic string) string {
+	configDefaultBranchName, err := getConfigDefaultBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreateAndSwitchToBranch(topic); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Z", "landed\n"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Y", "not landed\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(configDefaultBranchName); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("Z", []byte("landed\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("Z"); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("land Z"); err != nil {
+		t.Fatal(err)
+	}
+	return configDefaultBranchName
+}
+
+func TestPatchID(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	defaultBranchName := setupLandedBranch(t, "topic")
+	if landed, err := PatchID(defaultBranchName); err != nil {
+		t.Fatal(err)
+	} else if original, err := PatchID("topic~1"); err != nil {
+		t.Fatal(err)
+	} else if other, err := PatchID("topic"); err != nil {
+		t.Fatal(err)
+	} else if root, err := PatchID(g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	} else {
+		expectNEq(t, "", landed)
+		expectEq(t, landed, original)
+		expectNEq(t, landed, other)
+		expectNEq(t, "", root)
+	}
+
+	// a commit that doesn't change anything has no patch ID
+	if err := Git("commit", "--allow-empty", "-m", "empty"); err != nil {
+		t.Fatal(err)
+	} else if empty, err := PatchID("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "", empty)
+	}
+}
+
+func TestCherry(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	defaultBranchName := setupLandedBranch(t, "topic")
+	if commits, err := Cherry(defaultBranchName, "topic"); err != nil {
+		t.Fatal(err)
+	} else if landed, err := RevParse("topic~1"); err != nil {
+		t.Fatal(err)
+	} else if notLanded, err := RevParse("topic"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(commits))
+		expectEq(t, CherryCommit{Hash: landed, Upstream: true}, commits[0])
+		expectEq(t, CherryCommit{Hash: notLanded, Upstream: false}, commits[1])
+	}
+
+	if commits, err := Cherry("topic", "topic"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(commits))
+	}
+}
@@ -0,0 +1,146 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RangeDiffStatus describes how a commit in one version of a range relates to the other version.
+type RangeDiffStatus int
+
+const (
+	// RangeDiffUnchanged pairs commits whose patches are identical
+	RangeDiffUnchanged RangeDiffStatus = iota
+	// RangeDiffModified pairs commits whose patches differ; the pair's Diff shows how
+	RangeDiffModified
+	// RangeDiffAdded is a commit that is only in the new range
+	RangeDiffAdded
+	// RangeDiffRemoved is a commit that is only in the old range
+	RangeDiffRemoved
+)
+
+func (s RangeDiffStatus) String() string {
+	switch s {
+	case RangeDiffUnchanged:
+		return "unchanged"
+	case RangeDiffModified:
+		return "modified"
+	case RangeDiffAdded:
+		return "added"
+	case RangeDiffRemoved:
+		return "removed"
+	default:
+		return fmt.Sprintf("RangeDiffStatus(%d)", int(s))
+	}
+}
+
+// RangeDiffPair is one line of `git range-diff` output. Indexes are 1-based positions in their
+// range; the index and hash of the side a commit is missing from are 0 and "".
+type RangeDiffPair struct {
+	Status   RangeDiffStatus
+	OldIndex int
+	Old      string
+	NewIndex int
+	New      string
+	Subject  string
+	// Diff is the diff between the two versions of the patch for RangeDiffModified pairs
+	Diff string
+}
+
+var rangeDiffHeader = regexp.MustCompile(`^\s*(-|\d+):\s+(-+|[0-9a-f]+) ([=!<>]) \s*(-|\d+):\s+(-+|[0-9a-f]+) (.*)$`)
+
+// RangeDiff compares two versions of a series of commits (e.g. "main@{1}..topic@{1}" and
+// "main..topic") with `git range-diff` and returns the matched pairs in git's order.
+func RangeDiff(oldRange, newRange string) ([]RangeDiffPair, error) {
+	if err := requireCapability(CapabilityRangeDiff); err != nil {
+		return nil, err
+	}
+	output, err := GitOutput("range-diff", "--no-color", oldRange, newRange)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := []RangeDiffPair{}
+	diff := []string{}
+	flushDiff := func() {
+		if len(pairs) > 0 && len(diff) > 0 {
+			pairs[len(pairs)-1].Diff = strings.Join(diff, "\n")
+		}
+		diff = diff[:0]
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		match := rangeDiffHeader.FindStringSubmatch(line)
+		if match == nil {
+			// inner diffs are indented by 4 spaces
+			if len(pairs) > 0 && pairs[len(pairs)-1].Status == RangeDiffModified {
+				diff = append(diff, strings.TrimPrefix(line, "    "))
+			}
+			continue
+		}
+		flushDiff()
+
+		pair := RangeDiffPair{Subject: match[6]}
+		if match[1] != "-" {
+			pair.OldIndex, _ = strconv.Atoi(match[1])
+			pair.Old = match[2]
+		}
+		if match[4] != "-" {
+			pair.NewIndex, _ = strconv.Atoi(match[4])
+			pair.New = match[5]
+		}
+		switch match[3] {
+		case "=":
+			pair.Status = RangeDiffUnchanged
+		case "!":
+			pair.Status = RangeDiffModified
+		case "<":
+			pair.Status = RangeDiffRemoved
+		case ">":
+			pair.Status = RangeDiffAdded
+		}
+		pairs = append(pairs, pair)
+	}
+	flushDiff()
+
+	if err := expandRangeDiffHashes(pairs); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// expandRangeDiffHashes replaces the abbreviated hashes range-diff prints with full hashes.
+func expandRangeDiffHashes(pairs []RangeDiffPair) error {
+	abbrevs := []string{}
+	for _, pair := range pairs {
+		for _, hash := range []string{pair.Old, pair.New} {
+			if hash != "" {
+				abbrevs = append(abbrevs, hash)
+			}
+		}
+	}
+	if len(abbrevs) == 0 {
+		return nil
+	}
+
+	output, err := GitOutput(append([]string{"rev-parse"}, abbrevs...)...)
+	if err != nil {
+		return err
+	}
+	full := map[string]string{}
+	for i, hash := range strings.Split(output, "\n") {
+		full[abbrevs[i]] = hash
+	}
+
+	for i := range pairs {
+		if pairs[i].Old != "" {
+			pairs[i].Old = full[pairs[i].Old]
+		}
+		if pairs[i].New != "" {
+			pairs[i].New = full[pairs[i].New]
+		}
+	}
+	return nil
+}
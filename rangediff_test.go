@@ -0,0 +1,98 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func commitFileContent(name, content string) error {
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		return err
+	} else if err := Add(name); err != nil {
+		return err
+	} else if err := Commit(fmt.Sprintf("file %s", name)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func numberedLines(n int, last string) string {
+	var sb strings.Builder
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+	sb.WriteString(last + "\n")
+	return sb.String()
+}
+
+func TestRangeDiff(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	base := g_RefNames[len(g_RefNames)-1]
+	if err := CreateAndSwitchToBranch("v1"); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("X"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Y", numberedLines(20, "20")); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Z", "removed\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(base); err != nil {
+		t.Fatal(err)
+	} else if err := CreateAndSwitchToBranch("v2"); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("X"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Y", numberedLines(20, "twenty")); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("W"); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := RangeDiff(base+"..v1", base+"..v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byStatus := map[RangeDiffStatus][]RangeDiffPair{}
+	for _, pair := range pairs {
+		byStatus[pair.Status] = append(byStatus[pair.Status], pair)
+	}
+	expectEq(t, 4, len(pairs))
+	expectEq(t, 1, len(byStatus[RangeDiffUnchanged]))
+	expectEq(t, 1, len(byStatus[RangeDiffModified]))
+	expectEq(t, 1, len(byStatus[RangeDiffAdded]))
+	expectEq(t, 1, len(byStatus[RangeDiffRemoved]))
+
+	if hash, err := RevParse("v1~2"); err != nil {
+		t.Fatal(err)
+	} else {
+		unchanged := byStatus[RangeDiffUnchanged][0]
+		expectEq(t, "file X", unchanged.Subject)
+		expectEq(t, 1, unchanged.OldIndex)
+		expectEq(t, hash, unchanged.Old)
+	}
+
+	modified := byStatus[RangeDiffModified][0]
+	expectEq(t, "file Y", modified.Subject)
+	expectTrue(t, strings.Contains(modified.Diff, "-+20"))
+	expectTrue(t, strings.Contains(modified.Diff, "++twenty"))
+
+	removed := byStatus[RangeDiffRemoved][0]
+	expectEq(t, "file Z", removed.Subject)
+	expectEq(t, 0, removed.NewIndex)
+	expectEq(t, "", removed.New)
+
+	if hash, err := RevParse("v2"); err != nil {
+		t.Fatal(err)
+	} else {
+		added := byStatus[RangeDiffAdded][0]
+		expectEq(t, "file W", added.Subject)
+		expectEq(t, 3, added.NewIndex)
+		expectEq(t, hash, added.New)
+		expectEq(t, "", added.Old)
+	}
+}
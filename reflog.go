@@ -0,0 +1,75 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ReflogEntry is one update of a ref recorded in its reflog. Old is all zeros if the ref was
+// created by the update.
+type ReflogEntry struct {
+	Old     string
+	New     string
+	Name    string
+	Email   string
+	Time    time.Time
+	Message string
+}
+
+// Reflog returns the reflog of ref, newest entry first. ref may be "HEAD", a full ref name or a
+// branch name. A ref without a reflog has no entries.
+func Reflog(ref string) ([]ReflogEntry, error) {
+	fullName := ref
+	if ref != "HEAD" && !strings.HasPrefix(ref, "refs/") {
+		var err error
+		if fullName, err = GitOutput("rev-parse", "--symbolic-full-name", ref); err != nil {
+			return nil, err
+		} else if fullName == "" {
+			return nil, fmt.Errorf("%s is not a ref", ref)
+		}
+	}
+
+	path, err := gitPath("logs/" + fullName)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []ReflogEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseReflog(string(bs))
+}
+
+// parseReflog parses the contents of a reflog file, which has one
+// "<old> <new> <name> <<email>> <time> <tz>\t<message>" line per update, oldest first.
+func parseReflog(content string) ([]ReflogEntry, error) {
+	entries := []ReflogEntry{}
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			continue
+		}
+
+		info, message, _ := strings.Cut(line, "\t")
+		old, info, _ := strings.Cut(info, " ")
+		new, info, _ := strings.Cut(info, " ")
+		identity, date, found := strings.Cut(info, "> ")
+		name, email, _ := strings.Cut(identity, " <")
+		seconds, tz, _ := strings.Cut(date, " ")
+		if !found || old == "" || new == "" {
+			return nil, fmt.Errorf("unexpected reflog entry: %q", line)
+		}
+
+		entry := ReflogEntry{Old: old, New: new, Name: name, Email: email, Message: message}
+		entry.Time = inGitZone(parseUnixTime(seconds, time.Time{}), tz)
+		entries = append(entries, entry)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
@@ -0,0 +1,70 @@
+package git
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReflog(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	branch, err := GetCurrentBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Git("commit", "--amend", "--no-edit", "--date", "1112912053 +0200"); err != nil {
+		t.Fatal(err)
+	} else if err := Git("update-ref", "-m", "move back", "refs/heads/"+branch, g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	amended, err := RevParse("HEAD@{1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{branch, "refs/heads/" + branch} {
+		entries, err := Reflog(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectEq(t, len(k_FileNames)+2, len(entries))
+		expectEq(t, ReflogEntry{
+			Old:     amended,
+			New:     g_RefNames[0],
+			Name:    entries[0].Name,
+			Email:   entries[0].Email,
+			Time:    entries[0].Time,
+			Message: "move back",
+		}, entries[0])
+		expectEq(t, g_RefNames[len(g_RefNames)-1], entries[1].Old)
+		expectEq(t, amended, entries[1].New)
+		expectEq(t, "commit (amend): file F", entries[1].Message)
+		expectEq(t, "0000000000000000000000000000000000000000", entries[len(entries)-1].Old)
+		expectEq(t, "commit (initial): file A", entries[len(entries)-1].Message)
+		expectTrue(t, time.Since(entries[0].Time) < time.Hour)
+	}
+
+	if entries, err := Reflog("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if name, err := GitOutput("config", "user.name"); err != nil {
+		t.Fatal(err)
+	} else {
+		// updating the checked out branch is logged for HEAD too
+		expectEq(t, len(k_FileNames)+2, len(entries))
+		expectEq(t, "move back", entries[0].Message)
+		expectEq(t, name, entries[0].Name)
+	}
+
+	if entries, err := Reflog("refs/heads/no-reflog"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(entries))
+	}
+
+	if _, err := Reflog("does-not-exist"); err == nil {
+		t.Fatal("Expected an error for a ref that doesn't exist")
+	}
+}
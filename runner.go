@@ -0,0 +1,302 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Runner runs the git commands for the package. cmd describes the command: Args (starting with
+// "git"), Env, Dir, Stdin, Stdout and Stderr; a Runner needn't actually execute it. If git exits
+// with a non-zero status, the error must have an `ExitCode() int` method, as *exec.ExitError and
+// *ExitError do.
+type Runner interface {
+	Run(cmd *exec.Cmd) error
+}
+
+// CommandRunner is the Runner every function in the package uses. It's an ExecRunner by default.
+var CommandRunner Runner = &ExecRunner{}
+
+// ExecRunner runs git as a subprocess.
+type ExecRunner struct {
+	// GitPath is the git binary to run. If empty, "git" is looked up in PATH. It can be changed
+	// between commands; Version and the capability checks follow it.
+	GitPath string
+	// Dir is the directory git runs in, unless a command sets its own. If empty, git runs in the
+	// current directory.
+	Dir string
+	// Env is extra environment variables, as "NAME=value", added to every command after those of
+	// Environment, so they take precedence.
+	Env []string
+	// Environment is applied to every command. Its config overrides and variables are added when
+	// the command runs, so they aren't included in CommandTrace or recorded transcripts.
+	Environment Environment
+}
+
+// MinimumVersion is the oldest version of git NewExecRunner accepts. Features newer than this are
+// checked separately; see Capabilities.
+var MinimumVersion = GitVersion{2, 0, 0}
+
+// NewExecRunner returns an ExecRunner that runs the git binary at gitPath, or "git" from PATH if
+// gitPath is "". It returns an error if the binary can't be found or is older than MinimumVersion.
+func NewExecRunner(gitPath string) (*ExecRunner, error) {
+	if gitPath == "" {
+		gitPath = "git"
+	}
+	path, err := exec.LookPath(gitPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ExecRunner{GitPath: path}
+	if v, err := r.version(); err != nil {
+		return nil, err
+	} else if !v.AtLeast(MinimumVersion) {
+		return nil, fmt.Errorf("%s is git %s, but git %s or later is required", path, v, MinimumVersion)
+	}
+	return r, nil
+}
+
+func (r *ExecRunner) Run(cmd *exec.Cmd) error {
+	if arg := r.Environment.args(); len(arg) > 0 {
+		cmd.Args = append(append([]string{cmd.Args[0]}, arg...), cmd.Args[1:]...)
+	}
+	vars := r.Environment.vars()
+	if r.Environment.IgnoreGlobalConfig {
+		if v, err := r.version(); err != nil {
+			return err
+		} else if !v.AtLeast(Capabilities[CapabilityConfigGlobalEnv]) {
+			// older gits ignore GIT_CONFIG_GLOBAL, but can't find a global config in a home
+			// directory that isn't a directory
+			vars = append(vars, "HOME="+os.DevNull, "XDG_CONFIG_HOME="+os.DevNull)
+		}
+	}
+	if vars := append(vars, r.Env...); len(vars) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, vars...)
+	}
+	if cmd.Dir == "" {
+		cmd.Dir = r.Dir
+	}
+	if r.GitPath == "" {
+		return cmd.Run()
+	}
+
+	// cmd has already looked up "git" in PATH, which may have failed, so run a copy instead
+	gitCmd := exec.Command(r.GitPath, cmd.Args[1:]...)
+	gitCmd.Env, gitCmd.Dir = cmd.Env, cmd.Dir
+	gitCmd.Stdin, gitCmd.Stdout, gitCmd.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+	gitCmd.ExtraFiles, gitCmd.SysProcAttr = cmd.ExtraFiles, cmd.SysProcAttr
+	err := gitCmd.Run()
+	// so that errors quoting the command name the git that ran
+	cmd.Path = gitCmd.Path
+	cmd.Process, cmd.ProcessState = gitCmd.Process, gitCmd.ProcessState
+	return err
+}
+
+// version returns the version of the git binary r runs. It's looked up without r's Environment the
+// first time, and shared with Version after that.
+func (r *ExecRunner) version() (GitVersion, error) {
+	key := newVersionKey(r)
+	versionsMutex.Lock()
+	v, ok := versions[key]
+	versionsMutex.Unlock()
+	if ok {
+		return v, nil
+	}
+
+	path := r.GitPath
+	if path == "" {
+		path = "git"
+	}
+	output, err := exec.Command(path, "version").Output()
+	if err != nil {
+		return GitVersion{}, fmt.Errorf("%s: %s version", err, path)
+	}
+	if v, err = ParseVersion(string(output)); err != nil {
+		return GitVersion{}, err
+	}
+
+	versionsMutex.Lock()
+	versions[key] = v
+	versionsMutex.Unlock()
+	return v, nil
+}
+
+// workingPath returns path relative to the directory git runs in, for functions that read or
+// write files in the working tree themselves.
+func workingPath(path string) string {
+	if r, ok := CommandRunner.(*ExecRunner); ok && r.Dir != "" && !filepath.IsAbs(path) {
+		return filepath.Join(r.Dir, path)
+	}
+	return path
+}
+
+// ExitError is the error a Runner that doesn't execute git returns for a non-zero exit status.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// ScriptedCommand is one command expected by a ScriptedRunner and the output it produces, or one
+// command recorded by a RecordingRunner. Args are the arguments after "git".
+type ScriptedCommand struct {
+	Args     []string `json:"args"`
+	Stdin    string   `json:"stdin,omitempty"`
+	Stdout   string   `json:"stdout,omitempty"`
+	Stderr   string   `json:"stderr,omitempty"`
+	ExitCode int      `json:"exit_code,omitempty"`
+}
+
+// ScriptedRunner is a fake Runner that expects to be asked to run Commands in order, and writes
+// their output instead of running git. Running anything else is an error. If a command's Stdin is
+// set, the input must match too.
+type ScriptedRunner struct {
+	Commands []ScriptedCommand
+
+	mu   sync.Mutex
+	next int
+}
+
+func (r *ScriptedRunner) Run(cmd *exec.Cmd) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	args := strings.Join(cmd.Args[1:], " ")
+	if r.next >= len(r.Commands) {
+		return fmt.Errorf("unexpected git command after the end of the script: git %s", args)
+	}
+	expected := r.Commands[r.next]
+	if strings.Join(expected.Args, " ") != args {
+		return fmt.Errorf("unexpected git command: git %s, expected git %s", args, strings.Join(expected.Args, " "))
+	}
+
+	if expected.Stdin != "" {
+		if cmd.Stdin == nil {
+			return fmt.Errorf("expected input for git %s", args)
+		} else if stdin, err := io.ReadAll(cmd.Stdin); err != nil {
+			return err
+		} else if string(stdin) != expected.Stdin {
+			return fmt.Errorf("unexpected input for git %s: %q, expected %q", args, stdin, expected.Stdin)
+		}
+	}
+	r.next++
+
+	if cmd.Stdout != nil {
+		if _, err := io.WriteString(cmd.Stdout, expected.Stdout); err != nil {
+			return err
+		}
+	}
+	if cmd.Stderr != nil {
+		if _, err := io.WriteString(cmd.Stderr, expected.Stderr); err != nil {
+			return err
+		}
+	}
+	if expected.ExitCode != 0 {
+		return &ExitError{Code: expected.ExitCode}
+	}
+	return nil
+}
+
+// Remaining returns the commands that haven't been run yet.
+func (r *ScriptedRunner) Remaining() []ScriptedCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.Commands[r.next:]
+}
+
+// ReplayTranscript returns a ScriptedRunner that replays the transcript saved by
+// RecordingRunner.Save at path, e.g. a file in testdata.
+func ReplayTranscript(path string) (*ScriptedRunner, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ScriptedRunner{}
+	if err := json.Unmarshal(bs, &r.Commands); err != nil {
+		return nil, fmt.Errorf("invalid transcript %s: %s", path, err)
+	}
+	return r, nil
+}
+
+// RecordingRunner runs commands with Runner, or an ExecRunner if Runner is nil, and records them
+// along with their output so they can be saved as a transcript. Input and output going straight
+// to a file, such as the terminal, isn't recorded. Transcripts are JSON, so binary output isn't
+// preserved exactly.
+type RecordingRunner struct {
+	Runner Runner
+
+	mu       sync.Mutex
+	commands []ScriptedCommand
+}
+
+func (r *RecordingRunner) Run(cmd *exec.Cmd) error {
+	command := ScriptedCommand{Args: append([]string{}, cmd.Args[1:]...)}
+
+	stdin := &bytes.Buffer{}
+	if _, isFile := cmd.Stdin.(*os.File); cmd.Stdin != nil && !isFile {
+		cmd.Stdin = io.TeeReader(cmd.Stdin, stdin)
+	}
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = teeWriter(cmd.Stdout, stdout)
+	cmd.Stderr = teeWriter(cmd.Stderr, stderr)
+
+	runner := r.Runner
+	if runner == nil {
+		runner = &ExecRunner{}
+	}
+	err := runner.Run(cmd)
+
+	command.Stdin, command.Stdout, command.Stderr = stdin.String(), stdout.String(), stderr.String()
+	command.ExitCode = exitCode(err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, command)
+	return err
+}
+
+// Commands returns the commands recorded so far.
+func (r *RecordingRunner) Commands() []ScriptedCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]ScriptedCommand{}, r.commands...)
+}
+
+// Save writes the commands recorded so far to path as a transcript for ReplayTranscript.
+func (r *RecordingRunner) Save(path string) error {
+	bs, err := json.MarshalIndent(r.Commands(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(bs, '\n'), 0644)
+}
+
+// teeWriter returns a writer that writes to w and also to copy, unless w is nil or a file, which
+// is left for exec to pass straight to git.
+func teeWriter(w io.Writer, copy io.Writer) io.Writer {
+	if _, isFile := w.(*os.File); isFile {
+		return w
+	} else if w == nil {
+		return copy
+	}
+	return io.MultiWriter(w, copy)
+}
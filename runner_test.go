@@ -0,0 +1,241 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useRunner sets CommandRunner until the returned cleanup function is called.
+func useRunner(r Runner) (cleanup func()) {
+	previous := CommandRunner
+	CommandRunner = r
+	return func() { CommandRunner = previous }
+}
+
+func TestScriptedRunner(t *testing.T) {
+	runner := &ScriptedRunner{Commands: []ScriptedCommand{
+		{Args: []string{"merge-base", "--is-ancestor", "A", "B"}},
+		{Args: []string{"diff", "--quiet", "A", "B"}, ExitCode: 1},
+		{Args: []string{"status", "-s"}, Stdout: " M A\n"},
+		{Args: []string{"commit", "-F", "-"}, Stdin: "message\n"},
+		{Args: []string{"rev-parse", "--verify", "HEAD"}, Stdout: "0123\n"},
+	}}
+	defer useRunner(runner)()
+
+	if isAncestor, err := IsAncestor("A", "B"); err != nil {
+		t.Fatal(err)
+	} else if isDifferent, err := IsDifferent("A", "B"); err != nil {
+		t.Fatal(err)
+	} else if hasChanges, err := HasChanges(); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("message\n"); err != nil {
+		t.Fatal(err)
+	} else if hash, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, isAncestor)
+		expectTrue(t, isDifferent)
+		expectTrue(t, hasChanges)
+		expectEq(t, "0123", hash)
+		expectEq(t, 0, len(runner.Remaining()))
+	}
+
+	if err := Checkout("A"); err == nil {
+		t.Fatal("Expected an error for a command that isn't in the script")
+	} else {
+		expectTrue(t, strings.Contains(err.Error(), "git checkout A"))
+	}
+}
+
+func TestScriptedRunnerMismatch(t *testing.T) {
+	runner := &ScriptedRunner{Commands: []ScriptedCommand{
+		{Args: []string{"commit", "-F", "-"}, Stdin: "expected\n"},
+	}}
+	defer useRunner(runner)()
+
+	if err := Checkout("A"); err == nil {
+		t.Fatal("Expected an error for the wrong command")
+	} else if err := Commit("unexpected\n"); err == nil {
+		t.Fatal("Expected an error for the wrong input")
+	} else {
+		expectEq(t, 1, len(runner.Remaining()))
+	}
+}
+
+func TestReplayTranscript(t *testing.T) {
+	runner, err := ReplayTranscript(filepath.Join("testdata", "stack.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer useRunner(runner)()
+
+	if branch, err := GetCurrentBranchName(); err != nil {
+		t.Fatal(err)
+	} else if isAncestor, err := IsAncestor("main", "topic"); err != nil {
+		t.Fatal(err)
+	} else if hasChanges, err := HasChanges(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "topic", branch)
+		expectFalse(t, isAncestor)
+		expectFalse(t, hasChanges)
+	}
+
+	if _, err := RevParse("does-not-exist"); err == nil {
+		t.Fatal("Expected an error for an invalid ref")
+	} else {
+		expectTrue(t, strings.Contains(err.Error(), "exit status 128"))
+		expectTrue(t, strings.Contains(err.Error(), "Needed a single revision"))
+	}
+	expectEq(t, 0, len(runner.Remaining()))
+}
+
+func TestRecordingRunner(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	recorder := &RecordingRunner{Runner: CommandRunner}
+	cleanupRunner := useRunner(recorder)
+	head, err := RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := Diff(g_RefNames[0], g_RefNames[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	isAncestor, err := IsAncestor(g_RefNames[1], g_RefNames[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanupRunner()
+
+	transcript := filepath.Join(t.TempDir(), "transcript.json")
+	if err := recorder.Save(transcript); err != nil {
+		t.Fatal(err)
+	}
+	expectEq(t, 3, len(recorder.Commands()))
+	expectEq(t, 1, recorder.Commands()[2].ExitCode)
+
+	// replaying doesn't need the repository
+	runner, err := ReplayTranscript(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer useRunner(runner)()
+	if err := os.Chdir(os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if replayedHead, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if replayedDiff, err := Diff(g_RefNames[0], g_RefNames[1]); err != nil {
+		t.Fatal(err)
+	} else if replayedIsAncestor, err := IsAncestor(g_RefNames[1], g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, head, replayedHead)
+		expectEq(t, diff.String(), replayedDiff.String())
+		expectEq(t, isAncestor, replayedIsAncestor)
+	}
+}
+
+func TestNewExecRunner(t *testing.T) {
+	runner, err := NewExecRunner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTrue(t, filepath.IsAbs(runner.GitPath))
+	defer useRunner(runner)()
+	if v, err := Version(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, v.AtLeast(MinimumVersion))
+	}
+
+	_, err = NewExecRunner(filepath.Join(t.TempDir(), "git"))
+	expectTrue(t, err != nil)
+
+	// a git that's too old
+	oldGit := filepath.Join(t.TempDir(), "git")
+	if err := os.WriteFile(oldGit, []byte("#!/bin/sh\necho git version 1.8.5\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewExecRunner(oldGit)
+	expectTrue(t, err != nil && strings.Contains(err.Error(), "2.0.0 or later"))
+}
+
+func TestExecRunnerDirAndEnv(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	repo, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, err := NewExecRunner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.Dir = repo
+	runner.Environment = CommandRunner.(*ExecRunner).Environment
+	runner.Env = []string{"GIT_AUTHOR_NAME=Other Author"}
+	defer useRunner(runner)()
+	if err := os.Chdir(os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if head, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, g_RefNames[len(g_RefNames)-1], head)
+	}
+	if err := ResolveConflict("new", []byte("new\n")); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("new\n"); err != nil {
+		t.Fatal(err)
+	} else if author, err := GitOutput("log", "-1", "--format=%an"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "Other Author", author)
+	}
+	_, err = os.Stat(filepath.Join(repo, "new"))
+	expectTrue(t, err == nil)
+}
+
+func TestExecRunnerGitPath(t *testing.T) {
+	// a git that only knows its version
+	fakeGit := filepath.Join(t.TempDir(), "fake-git")
+	script := "#!/bin/sh\nif [ \"$1\" = version ]; then echo git version 2.31.1; else exit 1; fi\n"
+	if err := os.WriteFile(fakeGit, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewExecRunner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer useRunner(runner)()
+	if supported, err := Supports(CapabilityConfigGlobalEnv); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, supported)
+	}
+
+	// switching binaries switches the version used for capability checks
+	runner.GitPath = fakeGit
+	if supported, err := Supports(CapabilityConfigGlobalEnv); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, supported)
+	}
+
+	// and errors name the binary that ran
+	if _, err := GitOutput("status"); err == nil {
+		t.Fatal("Expected the fake git to fail")
+	} else {
+		expectTrue(t, strings.Contains(err.Error(), fakeGit+" status"))
+	}
+}
@@ -0,0 +1,150 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ConflictError is returned when an operation stops part-way through because of conflicts. The
+// operation can be resumed with its Continue function once the conflicts are resolved, or
+// abandoned with its Abort function.
+type ConflictError struct {
+	// Op is the git command that stopped, e.g. "cherry-pick"
+	Op string
+	// Paths are the paths with unresolved conflicts
+	Paths []string
+	Err   error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s stopped with conflicts in %s: %s", e.Op, strings.Join(e.Paths, ", "), e.Err)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// CherryPickOptions controls how CherryPick applies commits.
+type CherryPickOptions struct {
+	// RecordOrigin appends "(cherry picked from commit ...)" to the message (`-x`)
+	RecordOrigin bool
+	// Mainline is the 1-based parent number to diff merge commits against (`-m`)
+	Mainline int
+	// NoCommit applies the changes to the index and working tree without committing
+	NoCommit bool
+	// AllowEmpty keeps commits that were empty to begin with
+	AllowEmpty bool
+	// KeepRedundantCommits keeps commits that become empty because their changes are already in
+	// HEAD; otherwise the cherry-pick stops on them
+	KeepRedundantCommits bool
+}
+
+// RevertOptions controls how Revert reverts commits.
+type RevertOptions struct {
+	// Mainline is the 1-based parent number to revert merge commits relative to (`-m`)
+	Mainline int
+	// NoCommit applies the reverted changes to the index and working tree without committing
+	NoCommit bool
+}
+
+// CherryPick applies the changes introduced by commits on top of HEAD. If it stops because of
+// conflicts it returns a *ConflictError.
+func CherryPick(commits []string, opts CherryPickOptions) error {
+	arg := []string{}
+	if opts.RecordOrigin {
+		arg = append(arg, "-x")
+	}
+	if opts.Mainline > 0 {
+		arg = append(arg, "-m", strconv.Itoa(opts.Mainline))
+	}
+	if opts.NoCommit {
+		arg = append(arg, "--no-commit")
+	}
+	if opts.AllowEmpty {
+		arg = append(arg, "--allow-empty")
+	}
+	if opts.KeepRedundantCommits {
+		arg = append(arg, "--keep-redundant-commits")
+	}
+	return runSequencer("cherry-pick", append(arg, commits...)...)
+}
+
+// CherryPickContinue resumes a cherry-pick after conflicts have been resolved and staged.
+func CherryPickContinue() error {
+	return runSequencer("cherry-pick", "--continue")
+}
+
+// CherryPickSkip skips the commit that a cherry-pick stopped on and carries on with the rest.
+func CherryPickSkip() error {
+	return runSequencer("cherry-pick", "--skip")
+}
+
+// CherryPickAbort abandons an in-progress cherry-pick and returns to the pre-sequence state.
+func CherryPickAbort() error {
+	return mutatingGit("cherry-pick", "--abort")
+}
+
+// Revert creates commits reverting the changes introduced by commits. If it stops because of
+// conflicts it returns a *ConflictError.
+func Revert(commits []string, opts RevertOptions) error {
+	arg := []string{"--no-edit"}
+	if opts.Mainline > 0 {
+		arg = append(arg, "-m", strconv.Itoa(opts.Mainline))
+	}
+	if opts.NoCommit {
+		arg = append(arg, "--no-commit")
+	}
+	return runSequencer("revert", append(arg, commits...)...)
+}
+
+// RevertContinue resumes a revert after conflicts have been resolved and staged.
+func RevertContinue() error {
+	return runSequencer("revert", "--continue")
+}
+
+// RevertSkip skips the commit that a revert stopped on and carries on with the rest.
+func RevertSkip() error {
+	return runSequencer("revert", "--skip")
+}
+
+// RevertAbort abandons an in-progress revert and returns to the pre-sequence state.
+func RevertAbort() error {
+	return mutatingGit("revert", "--abort")
+}
+
+// runSequencer runs a sequencer command such as cherry-pick or revert without ever opening an
+// editor, turning failures caused by conflicts into a *ConflictError.
+func runSequencer(op string, arg ...string) error {
+	return runSequencerWithInput(op, nil, arg...)
+}
+
+// runSequencerWithInput is runSequencer with stdin read from r.
+func runSequencerWithInput(op string, r io.Reader, arg ...string) error {
+	cmd := mutatingGitCmd(append([]string{"-c", "core.editor=true", op}, arg...)...)
+	cmd.Stdin = r
+	if _, err := cmd.FormatOutput(cmd.CombinedOutput()); err != nil {
+		if paths, pathsErr := unmergedPaths(); pathsErr == nil && len(paths) > 0 {
+			return &ConflictError{Op: op, Paths: paths, Err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+// unmergedPaths lists the paths that have unresolved conflicts.
+func unmergedPaths() ([]string, error) {
+	output, err := GitOutput("diff", "--name-only", "--diff-filter=U", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, path := range strings.Split(output, "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,164 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setupConflictingBranch creates a branch named topic from the default branch that writes theirs
+// to F, and commits ours to F on the default branch. It returns the default branch name.
+func setupConflictingBranch(t *testing.T, topic, ours, theirs string) string {
+	configDefaultBranchName, err := getConfigDefaultBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreateAndSwitchToBranch(topic); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("F", theirs); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(configDefaultBranchName); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("F", ours); err != nil {
+		t.Fatal(err)
+	}
+	return configDefaultBranchName
+}
+
+func TestCherryPick(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := CreateAndSwitchToBranch("topic"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("Z", "picked\n"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(g_RefNames[3]); err != nil {
+		t.Fatal(err)
+	} else if err := CherryPick([]string{"topic"}, CherryPickOptions{RecordOrigin: true}); err != nil {
+		t.Fatal(err)
+	} else if hash, err := RevParse("topic"); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%B"); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("Z"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, strings.Contains(desc, "(cherry picked from commit "+hash+")"))
+	}
+
+	// --no-commit leaves the change staged
+	if err := Checkout(g_RefNames[3]); err != nil {
+		t.Fatal(err)
+	} else if err := CherryPick([]string{"topic"}, CherryPickOptions{NoCommit: true}); err != nil {
+		t.Fatal(err)
+	} else if output, err := GitOutput("status", "-s"); err != nil {
+		t.Fatal(err)
+	} else if hash, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "A  Z", output)
+		expectEq(t, g_RefNames[3], hash)
+	}
+}
+
+func TestCherryPickConflict(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupConflictingBranch(t, "topic", "ours\n", "theirs\n")
+
+	var conflictErr *ConflictError
+	if err := CherryPick([]string{"topic"}, CherryPickOptions{}); !errors.As(err, &conflictErr) {
+		t.Fatal("Expected a conflict, got", err)
+	} else {
+		expectEq(t, "cherry-pick", conflictErr.Op)
+		expectEq(t, 1, len(conflictErr.Paths))
+		expectEq(t, "F", conflictErr.Paths[0])
+	}
+
+	if err := os.WriteFile("F", []byte("resolved\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("F"); err != nil {
+		t.Fatal(err)
+	} else if err := CherryPickContinue(); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "file F", desc)
+	}
+}
+
+func TestCherryPickAbort(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupConflictingBranch(t, "topic", "ours\n", "theirs\n")
+
+	if before, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if err := CherryPick([]string{"topic"}, CherryPickOptions{}); err == nil {
+		t.Fatal("Expected a conflict")
+	} else if err := CherryPickAbort(); err != nil {
+		t.Fatal(err)
+	} else if after, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if hasChanges, err := HasChanges(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, before, after)
+		expectFalse(t, hasChanges)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := Revert([]string{"HEAD"}, RevertOptions{}); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat("F"); !os.IsNotExist(err) {
+		t.Fatal("Expected F to be removed, got", err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, `Revert "file F"`, desc)
+	}
+
+	if err := Revert([]string{g_RefNames[4]}, RevertOptions{NoCommit: true}); err != nil {
+		t.Fatal(err)
+	} else if output, err := GitOutput("status", "-s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "D  E", output)
+	}
+}
+
+func TestRevertConflict(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := commitFileContent("F", "first\n"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("F", "second\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var conflictErr *ConflictError
+	if err := Revert([]string{"HEAD~1"}, RevertOptions{}); !errors.As(err, &conflictErr) {
+		t.Fatal("Expected a conflict, got", err)
+	} else {
+		expectEq(t, "F", conflictErr.Paths[0])
+	}
+
+	if err := RevertSkip(); err != nil {
+		t.Fatal(err)
+	} else if desc, err := FormatShowRefDescription("HEAD", "%s"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "file F", desc)
+	}
+}
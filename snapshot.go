@@ -0,0 +1,171 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupRefPrefix is the private namespace snapshots are stored under. A snapshot taken at time t
+// stores a copy of e.g. refs/heads/main as refs/go-git-utils/backup/<t>/heads/main.
+const backupRefPrefix = "refs/go-git-utils/backup/"
+
+// snapshotTimeFormat is the UTC timestamp naming each snapshot. It sorts chronologically and is a
+// valid ref name component.
+const snapshotTimeFormat = "20060102T150405.000000000Z"
+
+// Snapshot is a backup of the values of a set of refs at a point in time.
+type Snapshot struct {
+	// Name identifies the snapshot; it's the timestamp of the snapshot in its backup refs
+	Name string
+	Time time.Time
+	Refs []RefState
+}
+
+// SnapshotRefs backs up the current values of the refs matching patterns (as for
+// `git for-each-ref`, e.g. "refs/heads/") under a private namespace, so they can be restored with
+// RestoreSnapshot. If patterns is empty, all refs are backed up. Symbolic refs and existing
+// snapshots are skipped.
+func SnapshotRefs(patterns ...string) (*Snapshot, error) {
+	arg := append([]string{"for-each-ref", "--format=%(objectname) %(refname) %(symref)"}, patterns...)
+	output, err := GitOutput(arg...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	snapshot := &Snapshot{Name: now.Format(snapshotTimeFormat), Time: now, Refs: []RefState{}}
+	var sb strings.Builder
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(fields[1], backupRefPrefix) {
+			continue
+		}
+
+		state := RefState{Ref: fields[1], Hash: fields[0]}
+		snapshot.Refs = append(snapshot.Refs, state)
+		fmt.Fprintf(&sb, "create %s %s\n", snapshot.backupRef(state.Ref), state.Hash)
+	}
+
+	cmd := mutatingGitCmd("update-ref", "--stdin")
+	cmd.Stdin = strings.NewReader(sb.String())
+	if _, err := cmd.FormatOutput(cmd.CombinedOutput()); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ListSnapshots lists the snapshots taken by SnapshotRefs, newest first.
+func ListSnapshots() ([]Snapshot, error) {
+	output, err := GitOutput("for-each-ref", "--format=%(objectname) %(refname)", backupRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, line := range strings.Split(output, "\n") {
+		hash, backupRef, found := strings.Cut(line, " ")
+		name, ref, _ := strings.Cut(strings.TrimPrefix(backupRef, backupRefPrefix), "/")
+		if !found || ref == "" {
+			continue
+		}
+
+		if len(snapshots) == 0 || snapshots[len(snapshots)-1].Name != name {
+			t, err := time.Parse(snapshotTimeFormat, name)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected snapshot ref: %q", backupRef)
+			}
+			snapshots = append(snapshots, Snapshot{Name: name, Time: t})
+		}
+		snapshot := &snapshots[len(snapshots)-1]
+		snapshot.Refs = append(snapshot.Refs, RefState{Ref: "refs/" + ref, Hash: hash})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Name > snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// RestoreSnapshot sets every ref in the named snapshot back to its backed up value in a single
+// atomic ref update, recreating any that were deleted. Refs created since the snapshot are left
+// alone, and, as with `git update-ref`, the working tree and index aren't touched.
+func RestoreSnapshot(name string) error {
+	snapshot, err := findSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for _, state := range snapshot.Refs {
+		fmt.Fprintf(&sb, "update %s %s\n", state.Ref, state.Hash)
+	}
+	cmd := mutatingGitCmd("update-ref", "-m", fmt.Sprintf("restore snapshot %s", name), "--no-deref", "--stdin")
+	cmd.Stdin = strings.NewReader(sb.String())
+
+	_, err = cmd.FormatOutput(cmd.CombinedOutput())
+	return err
+}
+
+// DeleteSnapshot deletes the named snapshot's backup refs.
+func DeleteSnapshot(name string) error {
+	snapshot, err := findSnapshot(name)
+	if err != nil {
+		return err
+	}
+	return deleteSnapshots([]Snapshot{*snapshot})
+}
+
+// ExpireSnapshots deletes the snapshots taken before the specified time and returns their names.
+func ExpireSnapshots(before time.Time) ([]string, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []Snapshot{}
+	names := []string{}
+	for _, snapshot := range snapshots {
+		if snapshot.Time.Before(before) {
+			expired = append(expired, snapshot)
+			names = append(names, snapshot.Name)
+		}
+	}
+	return names, deleteSnapshots(expired)
+}
+
+func findSnapshot(name string) (*Snapshot, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Name == name {
+			return &snapshot, nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot named %q", name)
+}
+
+func deleteSnapshots(snapshots []Snapshot) error {
+	var sb strings.Builder
+	for _, snapshot := range snapshots {
+		for _, state := range snapshot.Refs {
+			fmt.Fprintf(&sb, "delete %s %s\n", snapshot.backupRef(state.Ref), state.Hash)
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+
+	cmd := mutatingGitCmd("update-ref", "--stdin")
+	cmd.Stdin = strings.NewReader(sb.String())
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	return err
+}
+
+// backupRef returns the name of the ref backing up ref in the snapshot.
+func (s *Snapshot) backupRef(ref string) string {
+	return backupRefPrefix + s.Name + "/" + strings.TrimPrefix(ref, "refs/")
+}
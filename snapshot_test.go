@@ -0,0 +1,120 @@
+package git
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotRefs(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	branch, err := GetCurrentBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateBranchForced("topic", g_RefNames[1]); err != nil {
+		t.Fatal(err)
+	} else if err := Git("tag", "v1", g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := SnapshotRefs("refs/heads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEq(t, 2, len(snapshot.Refs))
+	expectEq(t, RefState{Ref: "refs/heads/" + branch, Hash: g_RefNames[len(g_RefNames)-1]}, snapshot.Refs[0])
+	expectEq(t, RefState{Ref: "refs/heads/topic", Hash: g_RefNames[1]}, snapshot.Refs[1])
+
+	// snapshots of everything don't include other snapshots
+	all, err := SnapshotRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEq(t, 3, len(all.Refs))
+	expectEq(t, "refs/tags/v1", all.Refs[2].Ref)
+
+	if snapshots, err := ListSnapshots(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(snapshots))
+		expectEq(t, all.Name, snapshots[0].Name)
+		expectEq(t, snapshot.Name, snapshots[1].Name)
+		expectTrue(t, snapshot.Time.Equal(snapshots[1].Time))
+		expectEq(t, snapshot.Refs[1], snapshots[1].Refs[1])
+	}
+
+	if err := DeleteSnapshot(all.Name); err != nil {
+		t.Fatal(err)
+	} else if snapshots, err := ListSnapshots(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(snapshots))
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := CreateBranchForced("topic", g_RefNames[1]); err != nil {
+		t.Fatal(err)
+	} else if err := CreateBranchForced("other", g_RefNames[1]); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := SnapshotRefs("refs/heads/topic", "refs/heads/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ForceDeleteBranch("topic"); err != nil {
+		t.Fatal(err)
+	} else if err := CreateBranchForced("other", g_RefNames[2]); err != nil {
+		t.Fatal(err)
+	} else if err := CreateBranch("new"); err != nil {
+		t.Fatal(err)
+	} else if err := RestoreSnapshot(snapshot.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, branch := range []string{"topic", "other"} {
+		if hash, err := RevParse(branch); err != nil {
+			t.Fatal(err)
+		} else {
+			expectEq(t, g_RefNames[1], hash)
+		}
+	}
+	expectTrue(t, BranchExists("new"))
+
+	if err := RestoreSnapshot("does-not-exist"); err == nil {
+		t.Fatal("Expected an error for an unknown snapshot")
+	}
+}
+
+func TestExpireSnapshots(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	old, err := SnapshotRefs("refs/heads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+	recent, err := SnapshotRefs("refs/heads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names, err := ExpireSnapshots(cutoff); err != nil {
+		t.Fatal(err)
+	} else if snapshots, err := ListSnapshots(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(names))
+		expectEq(t, old.Name, names[0])
+		expectEq(t, 1, len(snapshots))
+		expectEq(t, recent.Name, snapshots[0].Name)
+	}
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// TraceHook, if set, is called after each git command the package runs, or would have run in
+// DryRun mode. It's called from the goroutine that ran the command.
+var TraceHook func(CommandTrace)
+
+// DryRun stops the helpers that change the repository's refs, index or working tree, or a remote,
+// from running their git commands. They report success without doing anything, and the commands
+// they would have run are passed to TraceHook. Read-only commands, like those looking up a branch's
+// push remote, still run, so helpers that report what git did, like Merge, report that nothing
+// changed.
+//
+// IndexSession, which only changes its own index, and FormatPatchFiles aren't affected.
+var DryRun bool
+
+// CommandTrace describes one git command.
+type CommandTrace struct {
+	// Args are the arguments after "git"
+	Args []string
+	// Env holds the environment variables set for the command in addition to, or overriding, those
+	// of this process
+	Env []string
+	// Dir is the directory the command ran in, or "" for the current directory
+	Dir      string
+	Duration time.Duration
+	// ExitCode is -1 if git couldn't be run or was killed
+	ExitCode int
+	// StdoutBytes and StderrBytes are the sizes of the output. Output going straight to a file,
+	// such as the terminal for Amend's editor, isn't counted.
+	StdoutBytes int64
+	StderrBytes int64
+	// DryRun is set if the command wasn't run because of DryRun
+	DryRun bool
+	Err    error
+}
+
+// Logger is the subset of *slog.Logger used by TraceLogger.
+type Logger interface {
+	Debug(msg string, args ...any)
+}
+
+// TraceLogger returns a TraceHook that logs each command at debug level, with the details of the
+// CommandTrace as attributes.
+func TraceLogger(logger Logger) func(CommandTrace) {
+	return func(trace CommandTrace) {
+		args := []any{
+			"args", trace.Args,
+			"env", trace.Env,
+			"dir", trace.Dir,
+			"duration", trace.Duration,
+			"exit_code", trace.ExitCode,
+			"stdout_bytes", trace.StdoutBytes,
+			"stderr_bytes", trace.StderrBytes,
+		}
+		if trace.DryRun {
+			args = append(args, "dry_run", true)
+		}
+		if trace.Err != nil {
+			args = append(args, "err", trace.Err)
+		}
+		logger.Debug("git", args...)
+	}
+}
+
+// exitCode returns the exit code reported by err, which is nil if the command succeeded.
+func exitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if err == nil {
+		return 0
+	} else if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	} else {
+		return -1
+	}
+}
+
+// extraEnv returns the variables in env that aren't set the same way in this process's
+// environment. A nil env means the command inherits the environment unchanged.
+func extraEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+
+	inherited := map[string]bool{}
+	for _, v := range os.Environ() {
+		inherited[v] = true
+	}
+	extra := []string{}
+	for _, v := range env {
+		if !inherited[v] {
+			extra = append(extra, v)
+		}
+	}
+	return extra
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countWrites wraps w in a countingWriter, unless w is nil or a file, which exec passes straight
+// to git.
+func countWrites(w io.Writer) (io.Writer, *countingWriter) {
+	if _, isFile := w.(*os.File); w == nil || isFile {
+		return w, &countingWriter{}
+	}
+	counter := &countingWriter{w: w}
+	return counter, counter
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// traceHook sets TraceHook to collect the traces of the commands run until the returned cleanup
+// function is called.
+func traceHook() (traces *[]CommandTrace, cleanup func()) {
+	traces = &[]CommandTrace{}
+	TraceHook = func(trace CommandTrace) {
+		*traces = append(*traces, trace)
+	}
+	return traces, func() { TraceHook = nil }
+}
+
+func TestTraceHook(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	traces, cleanupHook := traceHook()
+	defer cleanupHook()
+
+	if _, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else if _, err := RevParse("does-not-exist"); err == nil {
+		t.Fatal("Expected an error for an invalid ref")
+	}
+
+	expectEq(t, 2, len(*traces))
+	trace := (*traces)[0]
+	expectEq(t, "rev-parse --verify HEAD", strings.Join(trace.Args, " "))
+	expectEq(t, 0, trace.ExitCode)
+	expectEq(t, int64(41), trace.StdoutBytes)
+	expectEq(t, int64(0), trace.StderrBytes)
+	expectEq(t, 0, len(trace.Env))
+	expectTrue(t, trace.Duration > 0)
+	expectTrue(t, trace.Err == nil)
+
+	failed := (*traces)[1]
+	expectEq(t, 128, failed.ExitCode)
+	expectTrue(t, failed.StderrBytes > 0)
+	expectTrue(t, failed.Err != nil)
+
+	// only the variables added to the environment are reported
+	if _, err := ListIndex("other-index"); err != nil {
+		t.Fatal(err)
+	}
+	env := (*traces)[2].Env
+	expectEq(t, 1, len(env))
+	expectEq(t, "GIT_INDEX_FILE=other-index", env[0])
+}
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Debug(msg string, args ...any) {
+	line := msg
+	for _, arg := range args {
+		line += fmt.Sprintf(" %v", arg)
+	}
+	l.lines = append(l.lines, line)
+}
+
+func TestTraceLogger(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	logger := &testLogger{}
+	TraceHook = TraceLogger(logger)
+	defer func() { TraceHook = nil }()
+
+	if err := Git("status"); err != nil {
+		t.Fatal(err)
+	}
+	expectEq(t, 1, len(logger.lines))
+	expectTrue(t, strings.HasPrefix(logger.lines[0], "git args [status] env [] dir  duration "))
+	expectTrue(t, strings.Contains(logger.lines[0], "exit_code 0"))
+}
+
+func TestDryRun(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	traces, cleanupHook := traceHook()
+	defer cleanupHook()
+	DryRun = true
+	defer func() { DryRun = false }()
+
+	if err := CreateBranch("topic"); err != nil {
+		t.Fatal(err)
+	} else if err := Checkout(g_RefNames[0]); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("A", []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err := Add("A"); err != nil {
+		t.Fatal(err)
+	} else if err := Commit("not committed"); err != nil {
+		t.Fatal(err)
+	} else if err := Git("config", "branch.topic.remote", "origin"); err != nil {
+		t.Fatal(err)
+	} else if err := PushBranch("topic"); err != nil {
+		t.Fatal(err)
+	} else if _, err := SnapshotRefs(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectFalse(t, BranchExists("topic"))
+	if head, err := RevParse("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, g_RefNames[len(g_RefNames)-1], head)
+	}
+
+	dryRun := []string{}
+	for _, trace := range *traces {
+		if trace.DryRun {
+			dryRun = append(dryRun, strings.Join(trace.Args, " "))
+		}
+	}
+	expectEq(t, strings.Join([]string{
+		"branch topic",
+		"checkout " + g_RefNames[0],
+		"add -- A",
+		"commit -F -",
+		"push origin topic",
+		"update-ref --stdin",
+	}, "\n"), strings.Join(dryRun, "\n"))
+
+	// read-only commands still ran
+	expectTrue(t, len(*traces) > len(dryRun))
+	if staged, err := DiffNameStatus(DiffOptions{Cached: true}); err != nil {
+		t.Fatal(err)
+	} else if snapshots, err := ListSnapshots(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(staged))
+		expectEq(t, 0, len(snapshots))
+	}
+}
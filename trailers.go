@@ -0,0 +1,250 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trailer is a single `Key: Value` trailer line at the end of a commit message, such as
+// `Signed-off-by` or `Change-Id`. Other lines in the trailers, such as "(cherry picked from commit
+// ...)", have no Key and the whole line as their Value.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+func (t Trailer) String() string {
+	if t.Key == "" {
+		return t.Value
+	}
+	return fmt.Sprintf("%s: %s", t.Key, t.Value)
+}
+
+// TrailerIfExists is the action taken when a trailer with the same key already exists. It maps to
+// `git interpret-trailers --if-exists`.
+type TrailerIfExists string
+
+const (
+	IfExistsAddIfDifferentNeighbor TrailerIfExists = "addIfDifferentNeighbor"
+	IfExistsAddIfDifferent         TrailerIfExists = "addIfDifferent"
+	IfExistsAdd                    TrailerIfExists = "add"
+	IfExistsReplace                TrailerIfExists = "replace"
+	IfExistsDoNothing              TrailerIfExists = "doNothing"
+)
+
+// TrailerIfMissing is the action taken when no trailer with the same key exists. It maps to
+// `git interpret-trailers --if-missing`.
+type TrailerIfMissing string
+
+const (
+	IfMissingAdd       TrailerIfMissing = "add"
+	IfMissingDoNothing TrailerIfMissing = "doNothing"
+)
+
+// TrailerOptions controls how trailers are added to a message. Zero values use git's configured
+// defaults.
+type TrailerOptions struct {
+	IfExists  TrailerIfExists
+	IfMissing TrailerIfMissing
+}
+
+// Message is a commit message split into its subject, body and trailers.
+type Message struct {
+	Subject string
+	Body    string
+	// Trailers are the lines of the final trailer paragraph, in order, including any that aren't
+	// trailers
+	Trailers []Trailer
+}
+
+// ParseMessage splits a commit message into its subject, body and trailers.
+func ParseMessage(message string) (*Message, error) {
+	trailers, err := ParseTrailers(message)
+	if err != nil {
+		return nil, err
+	}
+
+	paragraphs := splitParagraphs(message)
+	m := &Message{Trailers: trailers}
+	if len(paragraphs) == 0 {
+		return m, nil
+	}
+
+	m.Subject = paragraphs[0]
+	paragraphs = paragraphs[1:]
+	if len(trailers) > 0 && len(paragraphs) > 0 {
+		// git only ever looks for trailers in the last paragraph
+		m.Trailers = trailerLines(paragraphs[len(paragraphs)-1], trailers)
+		paragraphs = paragraphs[:len(paragraphs)-1]
+	}
+	m.Body = strings.Join(paragraphs, "\n\n")
+	return m, nil
+}
+
+// GetRefMessage gets the commit message of the specified ref as a Message.
+func GetRefMessage(ref string) (*Message, error) {
+	if message, err := FormatShowRefDescription(ref, "%B"); err != nil {
+		return nil, err
+	} else {
+		return ParseMessage(message)
+	}
+}
+
+// String reassembles the message, with the subject, body and trailers separated by blank lines.
+func (m *Message) String() string {
+	paragraphs := []string{}
+	if m.Subject != "" {
+		paragraphs = append(paragraphs, m.Subject)
+	}
+	if m.Body != "" {
+		paragraphs = append(paragraphs, m.Body)
+	}
+	if len(m.Trailers) > 0 {
+		lines := make([]string, 0, len(m.Trailers))
+		for _, trailer := range m.Trailers {
+			lines = append(lines, trailer.String())
+		}
+		paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// TrailerValues returns the values of all trailers with the specified key, compared
+// case-insensitively as git does.
+func (m *Message) TrailerValues(key string) []string {
+	values := []string{}
+	for _, trailer := range m.Trailers {
+		if strings.EqualFold(trailer.Key, key) {
+			values = append(values, trailer.Value)
+		}
+	}
+	return values
+}
+
+// ParseTrailers returns the trailers in the message using `git interpret-trailers --parse`.
+func ParseTrailers(message string) ([]Trailer, error) {
+	cmd := GitCmd("interpret-trailers", "--parse", "--no-divider")
+	cmd.Stdin = strings.NewReader(message)
+
+	output, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if err != nil {
+		return nil, err
+	}
+
+	trailers := []Trailer{}
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, found := strings.Cut(line, ":"); found {
+			trailers = append(trailers, Trailer{Key: key, Value: strings.TrimSpace(value)})
+		}
+	}
+	return trailers, nil
+}
+
+// AddTrailers adds the trailers to the message according to opts and returns the new message.
+func AddTrailers(message string, opts TrailerOptions, trailers ...Trailer) (string, error) {
+	arg := []string{"interpret-trailers", "--no-divider"}
+	if opts.IfExists != "" {
+		arg = append(arg, "--if-exists", string(opts.IfExists))
+	}
+	if opts.IfMissing != "" {
+		arg = append(arg, "--if-missing", string(opts.IfMissing))
+	}
+	for _, trailer := range trailers {
+		arg = append(arg, "--trailer", trailer.String())
+	}
+
+	cmd := GitCmd(arg...)
+	// without a final newline, git appends new trailers to the last line's paragraph
+	cmd.Stdin = strings.NewReader(strings.TrimRight(message, "\n") + "\n")
+
+	return cmd.FormatOutput(cmd.CombinedOutput())
+}
+
+// ReplaceTrailers replaces any existing trailers with the same keys as trailers, adding them if
+// they're missing.
+func ReplaceTrailers(message string, trailers ...Trailer) (string, error) {
+	return AddTrailers(message, TrailerOptions{IfExists: IfExistsReplace, IfMissing: IfMissingAdd}, trailers...)
+}
+
+// RemoveTrailers removes all trailers with any of the specified keys from the message, leaving
+// the rest of the message as it was.
+func RemoveTrailers(message string, keys ...string) (string, error) {
+	if trailers, err := ParseTrailers(message); err != nil {
+		return "", err
+	} else if len(trailers) == 0 {
+		return message, nil
+	}
+
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	// git only ever looks for trailers in the last paragraph
+	start := len(lines)
+	for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+	}
+
+	kept := lines[:start]
+	removing := false
+	for _, line := range lines[start:] {
+		// indented lines continue the previous trailer
+		if strings.TrimLeft(line, " \t") == line {
+			key, _, found := strings.Cut(line, ":")
+			removing = found && hasKey(keys, strings.TrimSpace(key))
+		}
+		if !removing {
+			kept = append(kept, line)
+		}
+	}
+
+	result := strings.TrimRight(strings.Join(kept, "\n"), " \t\n")
+	if strings.HasSuffix(message, "\n") {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// trailerLines lines up the trailers git parsed from paragraph with its lines, keeping the lines
+// that aren't trailers as trailers with no Key.
+func trailerLines(paragraph string, trailers []Trailer) []Trailer {
+	lines := []Trailer{}
+	for _, line := range strings.Split(paragraph, "\n") {
+		key, _, found := strings.Cut(line, ":")
+		if found && len(trailers) > 0 && strings.EqualFold(strings.TrimSpace(key), trailers[0].Key) {
+			lines = append(lines, trailers[0])
+			trailers = trailers[1:]
+		} else if strings.TrimLeft(line, " \t") == line {
+			lines = append(lines, Trailer{Value: line})
+		}
+		// indented lines continue the previous trailer, and git has already unfolded them
+	}
+	return append(lines, trailers...)
+}
+
+// hasKey returns whether keys contains key, compared case-insensitively as git does.
+func hasKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitParagraphs splits text on blank lines, dropping empty paragraphs.
+func splitParagraphs(text string) []string {
+	paragraphs := []string{}
+	current := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
+				current = current[:0]
+			}
+			continue
+		}
+		current = append(current, strings.TrimRight(line, " \t\r"))
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
+	}
+	return paragraphs
+}
@@ -0,0 +1,139 @@
+package git
+
+import (
+	"strings"
+	"testing"
+)
+
+const k_TrailerMessage = `subject line
+
+body paragraph one
+
+body paragraph two
+
+Signed-off-by: A U Thor <author@example.com>
+Change-Id: I0123456789abcdef`
+
+func TestParseTrailers(t *testing.T) {
+	if trailers, err := ParseTrailers(k_TrailerMessage); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(trailers))
+		expectEq(t, Trailer{"Signed-off-by", "A U Thor <author@example.com>"}, trailers[0])
+		expectEq(t, Trailer{"Change-Id", "I0123456789abcdef"}, trailers[1])
+	}
+
+	// the subject is never a trailer
+	if trailers, err := ParseTrailers("Change-Id: I0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 0, len(trailers))
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	if m, err := ParseMessage(k_TrailerMessage); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "subject line", m.Subject)
+		expectEq(t, "body paragraph one\n\nbody paragraph two", m.Body)
+		expectEq(t, 2, len(m.Trailers))
+		expectEq(t, "I0123456789abcdef", m.TrailerValues("change-id")[0])
+		expectEq(t, k_TrailerMessage, m.String())
+	}
+
+	if m, err := ParseMessage("subject only"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "subject only", m.Subject)
+		expectEq(t, "", m.Body)
+		expectEq(t, 0, len(m.Trailers))
+	}
+}
+
+func TestGetRefMessage(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := AmendWithMessage(k_TrailerMessage); err != nil {
+		t.Fatal(err)
+	} else if m, err := GetRefMessage("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "subject line", m.Subject)
+		expectEq(t, 2, len(m.Trailers))
+	}
+}
+
+func TestAddTrailers(t *testing.T) {
+	reviewed := Trailer{"Reviewed-by", "R E Viewer <reviewer@example.com>"}
+	if message, err := AddTrailers("subject", TrailerOptions{}, reviewed); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "subject\n\n"+reviewed.String(), message)
+	}
+
+	// an existing Change-Id is kept when told to do nothing
+	changeID := Trailer{"Change-Id", "Ifedcba9876543210"}
+	opts := TrailerOptions{IfExists: IfExistsDoNothing, IfMissing: IfMissingAdd}
+	if message, err := AddTrailers(k_TrailerMessage, opts, changeID); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, k_TrailerMessage, message)
+	}
+}
+
+func TestReplaceTrailers(t *testing.T) {
+	changeID := Trailer{"Change-Id", "Ifedcba9876543210"}
+	if message, err := ReplaceTrailers(k_TrailerMessage, changeID); err != nil {
+		t.Fatal(err)
+	} else if m, err := ParseMessage(message); err != nil {
+		t.Fatal(err)
+	} else {
+		values := m.TrailerValues("Change-Id")
+		expectEq(t, 1, len(values))
+		expectEq(t, changeID.Value, values[0])
+	}
+}
+
+func TestRemoveTrailers(t *testing.T) {
+	if message, err := RemoveTrailers(k_TrailerMessage, "change-id"); err != nil {
+		t.Fatal(err)
+	} else if m, err := ParseMessage(message); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 1, len(m.Trailers))
+		expectEq(t, "Signed-off-by", m.Trailers[0].Key)
+		expectEq(t, "body paragraph one\n\nbody paragraph two", m.Body)
+	}
+
+	if message, err := RemoveTrailers(k_TrailerMessage, "Change-Id", "Signed-off-by"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "subject line\n\nbody paragraph one\n\nbody paragraph two", message)
+	}
+}
+
+func TestTrailersKeepOtherLines(t *testing.T) {
+	message := "subject line\n\nbody\n\n" +
+		"Reviewed-by: R E Viewer <reviewer@example.com>\n" +
+		"(cherry picked from commit 0123456789abcdef0123456789abcdef01234567)\n" +
+		"Signed-off-by: A U Thor <author@example.com>\n"
+
+	if m, err := ParseMessage(message); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 3, len(m.Trailers))
+		expectEq(t, "", m.Trailers[1].Key)
+		expectEq(t, 1, len(m.TrailerValues("Signed-off-by")))
+		expectEq(t, strings.TrimRight(message, "\n"), m.String())
+	}
+
+	if removed, err := RemoveTrailers(message, "reviewed-by"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "subject line\n\nbody\n\n"+
+			"(cherry picked from commit 0123456789abcdef0123456789abcdef01234567)\n"+
+			"Signed-off-by: A U Thor <author@example.com>\n", removed)
+	}
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TreeEntry is one entry of a tree object, as listed by `git ls-tree --long`.
+type TreeEntry struct {
+	Mode string
+	// Type is "blob", "tree" or "commit" (for submodules)
+	Type string
+	Hash string
+	// Size is the size of a blob in bytes, or -1 for trees and submodules
+	Size int64
+	// Path is relative to the root of the repository
+	Path string
+}
+
+// ReadFile reads the content of path at rev without touching the working tree or index. path is
+// relative to the root of the repository.
+func ReadFile(rev, path string) ([]byte, error) {
+	return ReadBlob(fmt.Sprintf("%s:%s", rev, path))
+}
+
+// ListTree lists the entries of the directory path at rev, or of the whole tree if path is "". If
+// recursive is set, the contents of subdirectories are listed instead of the subdirectories
+// themselves. path is relative to the root of the repository.
+func ListTree(rev, path string, recursive bool) ([]TreeEntry, error) {
+	arg := []string{"ls-tree", "-z", "--long", "--full-tree"}
+	if recursive {
+		arg = append(arg, "-r")
+	}
+	arg = append(arg, rev, "--")
+	if path != "" {
+		arg = append(arg, strings.TrimSuffix(path, "/")+"/")
+	}
+
+	output, err := gitRawOutput(arg...)
+	if err != nil {
+		return nil, err
+	}
+	return parseTreeEntries(string(output))
+}
+
+// Exists returns whether path exists at rev, as either a file or a directory. It's an error if rev
+// doesn't exist.
+func Exists(rev, path string) (bool, error) {
+	if output, err := gitRawOutput("ls-tree", "-z", "--name-only", "--full-tree", rev, "--", path); err != nil {
+		return false, err
+	} else {
+		return len(output) > 0, nil
+	}
+}
+
+// parseTreeEntries parses `ls-tree -z --long` output: "<mode> <type> <hash> <size>\t<path>" per
+// entry, with the size padded and "-" for anything other than a blob.
+func parseTreeEntries(output string) ([]TreeEntry, error) {
+	entries := []TreeEntry{}
+	for _, line := range strings.Split(output, "\x00") {
+		if line == "" {
+			continue
+		}
+
+		info, path, found := strings.Cut(line, "\t")
+		fields := strings.Fields(info)
+		if !found || len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected ls-tree entry: %q", line)
+		}
+
+		entry := TreeEntry{Mode: fields[0], Type: fields[1], Hash: fields[2], Size: -1, Path: path}
+		if fields[3] != "-" {
+			size, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected ls-tree entry: %q", line)
+			}
+			entry.Size = size
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"os"
+	"testing"
+)
+
+// setupTree commits a file in a subdirectory, then leaves the working tree different from HEAD.
+func setupTree(t *testing.T) {
+	if err := os.MkdirAll("dir/sub", 0755); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("dir/H", "h\n"); err != nil {
+		t.Fatal(err)
+	} else if err := commitFileContent("dir/sub/G", "committed\n"); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile("dir/sub/G", []byte("uncommitted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupTree(t)
+	if bs, err := ReadFile("HEAD", "dir/sub/G"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "committed\n", string(bs))
+	}
+
+	if _, err := ReadFile("HEAD~1", "dir/sub/G"); err == nil {
+		t.Fatal("Expected error reading a file that didn't exist yet")
+	} else if _, err := ReadFile("HEAD", "dir"); err == nil {
+		t.Fatal("Expected error reading a directory as a file")
+	}
+}
+
+func TestListTree(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupTree(t)
+	if entries, err := ListTree("HEAD", "dir", false); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(entries))
+		expectEq(t, TreeEntry{Mode: "100644", Type: "blob", Hash: entries[0].Hash, Size: 2, Path: "dir/H"}, entries[0])
+		expectEq(t, "tree", entries[1].Type)
+		expectEq(t, "040000", entries[1].Mode)
+		expectEq(t, int64(-1), entries[1].Size)
+		expectEq(t, "dir/sub", entries[1].Path)
+	}
+
+	if entries, err := ListTree("HEAD", "dir/", true); err != nil {
+		t.Fatal(err)
+	} else if hash, err := RevParse("HEAD:dir/sub/G"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(entries))
+		expectEq(t, TreeEntry{Mode: "100644", Type: "blob", Hash: hash, Size: 10, Path: "dir/sub/G"}, entries[1])
+	}
+
+	if entries, err := ListTree("HEAD", "", false); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, len(k_FileNames)+1, len(entries))
+		expectEq(t, "A", entries[0].Path)
+	}
+}
+
+func TestExists(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	setupTree(t)
+	for path, expected := range map[string]bool{"dir/sub/G": true, "dir/sub": true, "dir/G": false, "Z": false} {
+		if exists, err := Exists("HEAD", path); err != nil {
+			t.Fatal(err)
+		} else {
+			expectEq(t, expected, exists)
+		}
+	}
+
+	if exists, err := Exists("HEAD~1", "dir/sub/G"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectFalse(t, exists)
+	}
+
+	if _, err := Exists("does-not-exist", "A"); err == nil {
+		t.Fatal("Expected error for an invalid rev")
+	}
+}
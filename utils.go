@@ -3,13 +3,21 @@ package git
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// NotesRewriteRefs lists the notes refs (e.g. "refs/notes/commits") whose notes are copied from the
+// old to the new commit when Amend, AmendNoEdit, AmendWithMessage or Rebase rewrite commits. It is
+// empty by default, which matches git's default of leaving notes behind on the old commits.
+var NotesRewriteRefs []string
+
 // ShowRefDescription gets the description for the specified commit ref. If it succeeds, s contains
 // the description and err is nil. If it fails, s contains the error output and err contains the
 // error returned from Run().
@@ -22,45 +30,44 @@ func FormatShowRefDescription(ref, format string) (s string, err error) {
 }
 
 // Diff shows the diff/patch between two specific commits. If it succeeds, buf contains the patch
-// and err is nil. If it fails, buf contains the error output and err contains the error returned
-// from Run()
+// and err is nil. If it fails, err contains git's error output; it is never mixed into buf.
 func Diff(ref1, ref2 string) (buf *bytes.Buffer, err error) {
 	buf = &bytes.Buffer{}
-	cmd := exec.Command("git", "diff", ref1, ref2, "-p", "--no-color")
-	cmd.Stdout = buf
-	cmd.Stderr = buf
+	r, err := gitStream("diff", ref1, ref2, "-p", "--no-color")
+	if err != nil {
+		return buf, err
+	}
 
-	err = cmd.Run()
-	return
+	_, err = buf.ReadFrom(r)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	return buf, err
 }
 
+// IsDifferent returns whether there are any differences between two specific commits
 func IsDifferent(ref1, ref2 string) (bool, error) {
-	buf, err := Diff(ref1, ref2)
-	if err != nil {
-		return true, err
-	} else if buf.Len() == 0 {
+	cmd := GitCmd("diff", "--quiet", ref1, ref2)
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if cmd.ExitCode() == 0 {
 		return false, nil
+	} else if cmd.ExitCode() == 1 {
+		return true, nil
+	} else {
+		return true, err
 	}
-	return true, nil
 }
 
 // ApplyPatch applies the patch in buf to the working tree but doesn't add or commit it.
 func ApplyPatch(r io.Reader) error {
-	// we use --recount instead of trying to manually fix patch chunks ourselves
-	cmd := exec.Command("git", "apply", "--recount", "-")
-	cmd.Stdin = r
-
-	if output, err := cmd.CombinedOutput(); err != nil {
-		asExecuted := cmd.String()
-		return fmt.Errorf("%s: %s\n%s", err, asExecuted, output)
-	}
-	return nil
+	_, err := ApplyPatchWithOptions(r, ApplyOptions{})
+	return err
 }
 
 // HasChanges returns true if there are changes that have not been committed in the working tree
 func HasChanges() (bool, error) {
 	buf := &bytes.Buffer{}
-	cmd := exec.Command("git", "status", "-s")
+	cmd := GitCmd("status", "-s")
 	cmd.Stdout = buf
 
 	err := cmd.Run()
@@ -84,13 +91,21 @@ func HasChanges() (bool, error) {
 	return false, nil
 }
 
-// GetCurrentBranchName gets the current branch name
+// GetCurrentBranchName gets the current branch name, or "" if HEAD is detached
 func GetCurrentBranchName() (name string, err error) {
-	if output, err := exec.Command("git", "branch", "--show-current").CombinedOutput(); err != nil {
-		return "", fmt.Errorf("%s: %s", err, output)
-	} else {
-		return strings.TrimSpace(string(output)), nil
+	if supported, err := Supports(CapabilityShowCurrent); err != nil {
+		return "", err
+	} else if supported {
+		return GitOutput("branch", "--show-current")
+	}
+
+	// symbolic-ref fails when HEAD is detached, where --show-current prints nothing
+	cmd := GitCmd("symbolic-ref", "--quiet", "--short", "HEAD")
+	output, err := cmd.FormatOutput(cmd.CombinedOutput())
+	if cmd.ExitCode() == 1 {
+		return "", nil
 	}
+	return output, err
 }
 
 // BranchExists returns whether or not the specified branch name exists
@@ -99,83 +114,139 @@ func BranchExists(name string) bool {
 	return err == nil
 }
 
+// ListBranches lists the names of all local branches
+func ListBranches() ([]string, error) {
+	if output, err := GitOutput("for-each-ref", "--format=%(refname:short)", "refs/heads/"); err != nil {
+		return nil, err
+	} else if output == "" {
+		return []string{}, nil
+	} else {
+		return strings.Split(output, "\n"), nil
+	}
+}
+
 // Commit triggers a commit, bringing up the default editor with the specified message
 func Commit(message string) error {
-	cmd := exec.Command("git", "commit", "-F", "-")
+	if GenerateChangeIDs {
+		var err error
+		if message, err = AddChangeID(message); err != nil {
+			return err
+		}
+	}
+
+	cmd := mutatingGitCmd("commit", "-F", "-")
 	cmd.Stdin = strings.NewReader(message)
 
-	if output, err := cmd.CombinedOutput(); err != nil {
-		asExecuted := cmd.String()
-		return fmt.Errorf("%s: %s\n%s", err, asExecuted, output)
-	}
-	return nil
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	return err
 }
 
 // Amend runs `git commit --amend` to amend the details of the last commit. It binds to the terminal
 // so that in-terminal editors like vim can be used "normally"
 func Amend() error {
-	cmd := exec.Command("git", "commit", "--amend")
+	// if the Change-Id is deleted in the editor, it's put back afterwards
+	changeID := ""
+	if GenerateChangeIDs {
+		var err error
+		if changeID, err = GetChangeID("HEAD"); err != nil {
+			return err
+		}
+	}
+	if err := journal("commit --amend", "HEAD"); err != nil {
+		return err
+	}
+
+	cmd := mutatingGitCmd(append(notesRewriteArgs(), "commit", "--amend")...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	// the message may have been edited to drop the Change-Id, so check again afterwards
+	if arg, err := changeIDAmendArgs(changeID); err != nil {
+		return err
+	} else if arg[0] != "--no-edit" {
+		return mutatingGit(append(append(notesRewriteArgs(), "commit", "--amend"), arg...)...)
+	}
+	return nil
 }
 
 // AmendWithMessage runs `git commit --amend -m <message>`
 func AmendWithMessage(message string) error {
-	return Git("commit", "--amend", "-m", message)
+	if GenerateChangeIDs {
+		if changeID, err := GetChangeID("HEAD"); err != nil {
+			return err
+		} else if message, err = addChangeIDOrKeep(message, changeID); err != nil {
+			return err
+		}
+	}
+	if err := journal("commit --amend", "HEAD"); err != nil {
+		return err
+	}
+	return mutatingGit(append(notesRewriteArgs(), "commit", "--amend", "-m", message)...)
 }
 
 // Amend runs `git commit --amend --no-edit` to amend the details of the last commit
 func AmendNoEdit() error {
-	return Git("commit", "--amend", "--no-edit")
+	if arg, err := changeIDAmendArgs(""); err != nil {
+		return err
+	} else if err := journal("commit --amend --no-edit", "HEAD"); err != nil {
+		return err
+	} else {
+		return mutatingGit(append(append(notesRewriteArgs(), "commit", "--amend"), arg...)...)
+	}
 }
 
 // Checkout the specified ref
 func Checkout(ref string) error {
-	return Git("checkout", ref)
+	return mutatingGit("checkout", ref)
 }
 
 // CreateAndSwitchToBranch creates a new branch and switches to it (`git checkout -b`)
 func CreateAndSwitchToBranch(branchName string) error {
-	return Git("checkout", "-b", branchName)
+	return mutatingGit("checkout", "-b", branchName)
 }
 
 // CreateBranch creates a branch at HEAD but doesn't switch to it
 func CreateBranch(branchName string) error {
-	return Git("branch", branchName)
+	return mutatingGit("branch", branchName)
 }
 
 // CreateBranchForced creates a branch at ref but doesn't switch to it.
 func CreateBranchForced(branchName, ref string) error {
-	return Git("branch", "-f", branchName, ref)
+	if err := journal(fmt.Sprintf("branch -f %s %s", branchName, ref), "refs/heads/"+branchName); err != nil {
+		return err
+	}
+	return mutatingGit("branch", "-f", branchName, ref)
 }
 
 // ForceDeleteBranch force-deletes the specified branch
 func ForceDeleteBranch(branchName string) error {
-	return Git("branch", "-D", branchName)
+	if err := journal(fmt.Sprintf("branch -D %s", branchName), "refs/heads/"+branchName); err != nil {
+		return err
+	}
+	return mutatingGit("branch", "-D", branchName)
 }
 
 // RevParse gets the hash for a ref
 func RevParse(ref string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--verify", ref)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		asExecuted := cmd.String()
-		return "", fmt.Errorf("%s: %s\n%s", err, asExecuted, output)
-	} else {
-		return strings.TrimSpace(string(output)), nil
-	}
+	return GitOutput("rev-parse", "--verify", ref)
 }
 
 // Add does a `git add`
 func Add(paths ...string) error {
 	arg := append([]string{"add", "--"}, paths...)
-	return Git(arg...)
+	return mutatingGit(arg...)
 }
 
 // Rebase does a `git rebase`
 func Rebase(base, topic string) error {
-	return Git("rebase", base, topic)
+	if err := journal(fmt.Sprintf("rebase %s %s", base, topic), topic); err != nil {
+		return err
+	}
+	return mutatingGit(append(notesRewriteArgs(), "rebase", base, topic)...)
 }
 
 // Log returns a log as per the provided arguments
@@ -186,6 +257,9 @@ func Log(arg ...string) (string, error) {
 
 // GetForkPoint returns the common ancestor commit of the specified refs
 func GetForkPoint(ref string, arg ...string) (string, error) {
+	if err := requireCapability(CapabilityForkPoint); err != nil {
+		return "", err
+	}
 	arg = append([]string{"merge-base", "--fork-point", ref}, arg...)
 	if output, err := GitOutput(arg...); err != nil {
 		// verified that an error is returned when fully merged or no common ancestor exists
@@ -199,9 +273,9 @@ func GetForkPoint(ref string, arg ...string) (string, error) {
 func IsAncestor(ref1, ref2 string) (bool, error) {
 	cmd := GitCmd("merge-base", "--is-ancestor", ref1, ref2)
 	_, err := cmd.FormatOutput(cmd.CombinedOutput())
-	if cmd.ProcessState.ExitCode() == 0 {
+	if cmd.ExitCode() == 0 {
 		return true, nil
-	} else if cmd.ProcessState.ExitCode() == 1 {
+	} else if cmd.ExitCode() == 1 {
 		return false, nil
 	} else {
 		return false, err
@@ -226,7 +300,7 @@ func GetPushRemoteForBranch(branch string) (string, error) {
 
 // ForceAddNote replaces the note associated with the specified object.
 func ForceAddNotes(object, note string) error {
-	cmd := GitCmd("notes", "add", "--force", "--file", "-", object)
+	cmd := mutatingGitCmd("notes", "add", "--force", "--file", "-", object)
 	cmd.Stdin = strings.NewReader(note)
 
 	_, err := cmd.FormatOutput(cmd.CombinedOutput())
@@ -236,7 +310,7 @@ func ForceAddNotes(object, note string) error {
 // AppendNote appends the supplied note to any existing notes associated with the specified
 // object.
 func AppendNotes(object, note string) error {
-	cmd := GitCmd("notes", "append", "--file", "-", object)
+	cmd := mutatingGitCmd("notes", "append", "--file", "-", object)
 	cmd.Stdin = strings.NewReader(note)
 
 	_, err := cmd.FormatOutput(cmd.CombinedOutput())
@@ -248,9 +322,43 @@ func ShowNotes(object string) (string, error) {
 	return GitOutput("notes", "show", object)
 }
 
+// ForceCopyNotes copies the notes on the from object to the to object, replacing any notes the to
+// object already has.
+func ForceCopyNotes(from, to string) error {
+	return mutatingGit("notes", "copy", "--force", from, to)
+}
+
+// ForceCopyRewrittenNotes copies notes from each old commit in rewritten to the new commit it maps
+// to, e.g. using the old/new pairs reported by a post-rewrite hook.
+func ForceCopyRewrittenNotes(rewritten map[string]string) error {
+	var sb strings.Builder
+	for from, to := range rewritten {
+		fmt.Fprintf(&sb, "%s %s\n", from, to)
+	}
+
+	cmd := mutatingGitCmd("notes", "copy", "--force", "--stdin")
+	cmd.Stdin = strings.NewReader(sb.String())
+
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	return err
+}
+
+// notesRewriteArgs returns the config overrides that make commit rewriting commands carry the notes
+// in NotesRewriteRefs over to the rewritten commits.
+func notesRewriteArgs() []string {
+	if len(NotesRewriteRefs) == 0 {
+		return nil
+	}
+	arg := []string{"-c", "notes.rewrite.amend=true", "-c", "notes.rewrite.rebase=true"}
+	for _, ref := range NotesRewriteRefs {
+		arg = append(arg, "-c", fmt.Sprintf("notes.rewriteRef=%s", ref))
+	}
+	return arg
+}
+
 // Push does a `git push`
 func Push() error {
-	return Git("push")
+	return mutatingGit("push")
 }
 
 // PushBranch pushes a branch to its default remote without switching to it.
@@ -258,7 +366,7 @@ func PushBranch(branch string) error {
 	if remote, err := GetPushRemoteForBranch(branch); err != nil {
 		return err
 	} else {
-		return Git("push", remote, branch)
+		return mutatingGit("push", remote, branch)
 	}
 }
 
@@ -267,21 +375,94 @@ func ForcePushBranch(branch string) error {
 	if remote, err := GetPushRemoteForBranch(branch); err != nil {
 		return err
 	} else {
-		return Git("push", "-f", remote, branch)
+		return mutatingGit("push", "-f", remote, branch)
 	}
 }
 
 // PushAndSetUpstream sets the remote tracking branch and pushes
 func PushAndSetUpstream(remote, branch string) error {
-	return Git("push", "-u", remote, branch)
+	return mutatingGit("push", "-u", remote, branch)
 }
 
 type Cmd struct {
 	*exec.Cmd
+	// mutates is set for commands that DryRun stops from running
+	mutates  bool
+	exitCode int
 }
 
 func GitCmd(arg ...string) *Cmd {
-	return &Cmd{exec.Command("git", arg...)}
+	return &Cmd{Cmd: exec.Command("git", arg...)}
+}
+
+// mutatingGitCmd is GitCmd for commands that change the repository or a remote, which aren't run
+// in DryRun mode.
+func mutatingGitCmd(arg ...string) *Cmd {
+	cmd := GitCmd(arg...)
+	cmd.mutates = true
+	return cmd
+}
+
+// Run runs the command with CommandRunner and reports it to TraceHook. In DryRun mode, mutating
+// commands are only reported.
+func (cmd *Cmd) Run() error {
+	trace := CommandTrace{Args: cmd.Args[1:], Env: extraEnv(cmd.Env), Dir: cmd.Dir}
+	if DryRun && cmd.mutates {
+		trace.DryRun = true
+		if TraceHook != nil {
+			TraceHook(trace)
+		}
+		return nil
+	}
+
+	var stdout, stderr *countingWriter
+	cmd.Stdout, stdout = countWrites(cmd.Stdout)
+	cmd.Stderr, stderr = countWrites(cmd.Stderr)
+
+	start := time.Now()
+	err := CommandRunner.Run(cmd.Cmd)
+	cmd.exitCode = exitCode(err)
+	trace.Duration = time.Since(start)
+	trace.ExitCode = cmd.exitCode
+	trace.StdoutBytes, trace.StderrBytes = stdout.n, stderr.n
+	trace.Err = err
+	if TraceHook != nil {
+		TraceHook(trace)
+	}
+	return err
+}
+
+// ExitCode returns the exit code of the command once it has run. It's -1 if git couldn't be run or
+// was killed.
+func (cmd *Cmd) ExitCode() int {
+	return cmd.exitCode
+}
+
+// Output runs the command and returns its stdout.
+func (cmd *Cmd) Output() ([]byte, error) {
+	if cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+
+	err := cmd.Run()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its stdout and stderr together.
+func (cmd *Cmd) CombinedOutput() ([]byte, error) {
+	if cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	} else if cmd.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	output := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	return output.Bytes(), err
 }
 
 func GitOutput(arg ...string) (string, error) {
@@ -290,11 +471,108 @@ func GitOutput(arg ...string) (string, error) {
 	return cmd.FormatOutput(cmd.CombinedOutput())
 }
 
+// gitRawOutput runs git and returns its untrimmed stdout, for commands whose output is file content.
+// Unlike GitOutput, stderr is only included in the error.
+func gitRawOutput(arg ...string) ([]byte, error) {
+	cmd := GitCmd(arg...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	if output, err := cmd.Output(); err != nil {
+		asExecuted := cmd.String()
+		return nil, fmt.Errorf("%s: %s\n%s", err, asExecuted, stderr)
+	} else {
+		return output, nil
+	}
+}
+
+// gitStream starts git and returns a stream of its stdout. Closing the stream waits for git to exit
+// and returns its error, with stderr, if it failed. Closing before the end of the output stops git.
+func gitStream(arg ...string) (io.ReadCloser, error) {
+	cmd := GitCmd(arg...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	s := &outputStream{cmd: cmd, stdout: r, stderr: stderr, done: make(chan error, 1)}
+	go func() {
+		err := cmd.Run()
+		w.Close()
+		s.done <- err
+	}()
+	return s, nil
+}
+
+type outputStream struct {
+	cmd    *Cmd
+	stdout *io.PipeReader
+	stderr *bytes.Buffer
+	done   chan error
+	eof    bool
+
+	closed   bool
+	closeErr error
+}
+
+func (s *outputStream) Read(p []byte) (int, error) {
+	n, err := s.stdout.Read(p)
+	if err == io.EOF {
+		s.eof = true
+	}
+	return n, err
+}
+
+// Close waits for git to exit and returns its error, if any. Closing again returns the same error.
+func (s *outputStream) Close() error {
+	if !s.closed {
+		s.closed = true
+		s.closeErr = s.wait()
+	}
+	return s.closeErr
+}
+
+func (s *outputStream) wait() error {
+	cutOff := !s.eof
+	if cutOff {
+		s.stdout.Close()
+	}
+
+	if err := <-s.done; err == nil {
+		return nil
+	} else if cutOff && isBrokenPipe(err) {
+		// the reader doesn't want the rest, so git failing to write it isn't an error
+		return nil
+	} else {
+		asExecuted := s.cmd.String()
+		return fmt.Errorf("%s: %s\n%s", err, asExecuted, s.stderr)
+	}
+}
+
+// isBrokenPipe returns whether err is from git's output being closed before it finished writing,
+// either while copying it or by git being killed with SIGPIPE.
+func isBrokenPipe(err error) bool {
+	var exitErr *exec.ExitError
+	if errors.Is(err, io.ErrClosedPipe) {
+		return true
+	} else if errors.As(err, &exitErr) {
+		status, ok := exitErr.Sys().(syscall.WaitStatus)
+		return ok && status.Signaled() && status.Signal() == syscall.SIGPIPE
+	}
+	return false
+}
+
 func Git(arg ...string) error {
 	_, err := GitOutput(arg...)
 	return err
 }
 
+func mutatingGit(arg ...string) error {
+	cmd := mutatingGitCmd(arg...)
+	_, err := cmd.FormatOutput(cmd.CombinedOutput())
+	return err
+}
+
 func (cmd *Cmd) FormatOutput(output []byte, err error) (string, error) {
 	if err != nil {
 		asExecuted := cmd.String()
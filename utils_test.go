@@ -63,7 +63,19 @@ func commitBlankFile(name string) error {
 	return nil
 }
 
+// k_TestConfig is the only config tests see, so they don't depend on the developer's global config.
+var k_TestConfig = map[string]string{
+	"init.defaultBranch": "main",
+	"user.name":          "Test User",
+	"user.email":         "test.user@example.com",
+}
+
 func setupGitRepo(t *testing.T) (cleanup func()) {
+	env := HermeticEnvironment()
+	env.Config = k_TestConfig
+	previousRunner := CommandRunner
+	CommandRunner = &ExecRunner{Environment: env}
+
 	folder, err := os.MkdirTemp(os.TempDir(), "go-git-utils-test")
 	if err != nil {
 		t.Fatal(err)
@@ -91,6 +103,7 @@ func setupGitRepo(t *testing.T) (cleanup func()) {
 	}
 
 	return func() {
+		CommandRunner = previousRunner
 		os.Chdir(pwd)
 		if err := os.RemoveAll(folder); err != nil {
 			// not a test error, just messy
@@ -765,3 +778,104 @@ func TestShowNotes(t *testing.T) {
 		}
 	}
 }
+
+func TestNotesRewriteRefs(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	NotesRewriteRefs = []string{"refs/notes/commits"}
+	defer func() { NotesRewriteRefs = nil }()
+
+	const k_Note = "stack metadata"
+	newBranchName := "a-new-branch"
+	if err := ForceAddNotes("HEAD", k_Note); err != nil {
+		t.Fatal(err)
+	} else if err := AmendNoEdit(); err != nil {
+		t.Fatal(err)
+	} else if note, err := ShowNotes("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, k_Note, note)
+	}
+
+	// rebase a branch with a noted commit onto a rewritten base
+	if err := Checkout(g_RefNames[3]); err != nil {
+		t.Fatal(err)
+	} else if err := CreateAndSwitchToBranch(newBranchName); err != nil {
+		t.Fatal(err)
+	} else if err := commitBlankFile("Z"); err != nil {
+		t.Fatal(err)
+	} else if err := ForceAddNotes("HEAD", k_Note); err != nil {
+		t.Fatal(err)
+	} else if configDefaultBranchName, err := getConfigDefaultBranchName(); err != nil {
+		t.Fatal(err)
+	} else if err := Rebase(configDefaultBranchName, newBranchName); err != nil {
+		t.Fatal(err)
+	} else if note, err := ShowNotes("HEAD"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, k_Note, note)
+	}
+}
+
+func TestForceCopyNotes(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := ForceAddNotes(g_RefNames[1], "test1"); err != nil {
+		t.Fatal(err)
+	} else if err := ForceAddNotes(g_RefNames[2], "test2"); err != nil {
+		t.Fatal(err)
+	} else if err := ForceCopyNotes(g_RefNames[1], g_RefNames[2]); err != nil {
+		t.Fatal(err)
+	} else if note, err := ShowNotes(g_RefNames[2]); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "test1", note)
+	}
+}
+
+func TestForceCopyRewrittenNotes(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	rewritten := map[string]string{
+		g_RefNames[0]: g_RefNames[3],
+		g_RefNames[1]: g_RefNames[4],
+	}
+
+	if err := ForceAddNotes(g_RefNames[0], "test0"); err != nil {
+		t.Fatal(err)
+	} else if err := ForceAddNotes(g_RefNames[1], "test1"); err != nil {
+		t.Fatal(err)
+	} else if err := ForceCopyRewrittenNotes(rewritten); err != nil {
+		t.Fatal(err)
+	}
+
+	for from, to := range rewritten {
+		if expected, err := ShowNotes(from); err != nil {
+			t.Fatal(err)
+		} else if note, err := ShowNotes(to); err != nil {
+			t.Fatal(err)
+		} else {
+			expectEq(t, expected, note)
+		}
+	}
+}
+
+func TestListBranches(t *testing.T) {
+	cleanup := setupGitRepo(t)
+	defer cleanup()
+
+	if err := CreateBranch("a-new-branch"); err != nil {
+		t.Fatal(err)
+	} else if configDefaultBranchName, err := getConfigDefaultBranchName(); err != nil {
+		t.Fatal(err)
+	} else if branches, err := ListBranches(); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, 2, len(branches))
+		expectEq(t, "a-new-branch", branches[0])
+		expectEq(t, configDefaultBranchName, branches[1])
+	}
+}
@@ -0,0 +1,178 @@
+package git
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GitVersion is a git release version, e.g. 2.39.5.
+type GitVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+func (v GitVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast returns whether v is the same as or later than other.
+func (v GitVersion) AtLeast(other GitVersion) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	} else if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	} else {
+		return v.Patch >= other.Patch
+	}
+}
+
+// ParseVersion parses the output of `git version`, e.g. "git version 2.39.5",
+// "git version 2.37.1 (Apple Git-137.1)" or "git version 2.40.0.windows.1".
+func ParseVersion(output string) (GitVersion, error) {
+	fields := strings.Fields(output)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return GitVersion{}, fmt.Errorf("unexpected git version: %q", output)
+	}
+
+	numbers := []int{}
+	for _, part := range strings.Split(fields[2], ".") {
+		// release candidates look like "2.40.0-rc1"
+		if end := strings.IndexFunc(part, func(c rune) bool { return c < '0' || c > '9' }); end >= 0 {
+			part = part[:end]
+		}
+		if n, err := strconv.Atoi(part); err != nil || len(numbers) == 3 {
+			break
+		} else {
+			numbers = append(numbers, n)
+		}
+	}
+	if len(numbers) < 2 {
+		return GitVersion{}, fmt.Errorf("unexpected git version: %q", output)
+	}
+
+	v := GitVersion{Major: numbers[0], Minor: numbers[1]}
+	if len(numbers) > 2 {
+		v.Patch = numbers[2]
+	}
+	return v, nil
+}
+
+// versionKey identifies the git a runner runs, which for an ExecRunner can be changed with GitPath.
+type versionKey struct {
+	runner  Runner
+	gitPath string
+}
+
+func newVersionKey(runner Runner) versionKey {
+	key := versionKey{runner: runner}
+	if r, ok := runner.(*ExecRunner); ok {
+		key.gitPath = r.GitPath
+	}
+	return key
+}
+
+var versions = map[versionKey]GitVersion{}
+var versionsMutex sync.Mutex
+
+// Version returns the version of git that CommandRunner runs. It's only looked up once for each
+// runner, and each GitPath of an ExecRunner.
+func Version() (GitVersion, error) {
+	runner := CommandRunner
+	key := newVersionKey(runner)
+	// runners that can't be map keys are asked every time
+	cacheable := reflect.TypeOf(runner).Comparable()
+	if cacheable {
+		versionsMutex.Lock()
+		v, ok := versions[key]
+		versionsMutex.Unlock()
+		if ok {
+			return v, nil
+		}
+	}
+
+	output, err := GitOutput("version")
+	if err != nil {
+		return GitVersion{}, err
+	}
+	v, err := ParseVersion(output)
+	if err != nil {
+		return GitVersion{}, err
+	}
+
+	if cacheable {
+		versionsMutex.Lock()
+		versions[key] = v
+		versionsMutex.Unlock()
+	}
+	return v, nil
+}
+
+// Capability is a git feature the package uses that older versions of git don't have.
+type Capability string
+
+const (
+	CapabilityForkPoint          Capability = "merge-base --fork-point"
+	CapabilityRangeDiff          Capability = "range-diff"
+	CapabilityShowCurrent        Capability = "branch --show-current"
+	CapabilityRestore            Capability = "restore"
+	CapabilityPathFormat         Capability = "rev-parse --path-format"
+	CapabilityConfigGlobalEnv    Capability = "GIT_CONFIG_GLOBAL"
+	CapabilityAmEmpty            Capability = "am --empty"
+	CapabilityMergeTreeWriteTree Capability = "merge-tree --write-tree"
+)
+
+// Capabilities maps each Capability to the first version of git that has it.
+var Capabilities = map[Capability]GitVersion{
+	CapabilityForkPoint:          {1, 9, 0},
+	CapabilityRangeDiff:          {2, 19, 0},
+	CapabilityShowCurrent:        {2, 22, 0},
+	CapabilityRestore:            {2, 23, 0},
+	CapabilityPathFormat:         {2, 31, 0},
+	CapabilityConfigGlobalEnv:    {2, 32, 0},
+	CapabilityAmEmpty:            {2, 35, 0},
+	CapabilityMergeTreeWriteTree: {2, 38, 0},
+}
+
+// UnsupportedError is returned by functions that need a Capability the version of git being run
+// doesn't have.
+type UnsupportedError struct {
+	Capability Capability
+	Required   GitVersion
+	Version    GitVersion
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s requires git %s or later, but git is version %s", e.Capability, e.Required, e.Version)
+}
+
+// Supports returns whether the version of git being run has capability.
+func Supports(capability Capability) (bool, error) {
+	if err := requireCapability(capability); err == nil {
+		return true, nil
+	} else if _, unsupported := err.(*UnsupportedError); unsupported {
+		return false, nil
+	} else {
+		return false, err
+	}
+}
+
+// requireCapability returns an *UnsupportedError if the version of git being run doesn't have
+// capability.
+func requireCapability(capability Capability) error {
+	required, ok := Capabilities[capability]
+	if !ok {
+		return fmt.Errorf("unknown capability %q", capability)
+	}
+
+	v, err := Version()
+	if err != nil {
+		return err
+	} else if !v.AtLeast(required) {
+		return &UnsupportedError{Capability: capability, Required: required, Version: v}
+	}
+	return nil
+}
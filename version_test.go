@@ -0,0 +1,113 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	for output, expected := range map[string]GitVersion{
+		"git version 2.39.5":                      {2, 39, 5},
+		"git version 2.37.1 (Apple Git-137.1)":    {2, 37, 1},
+		"git version 2.40.0.windows.1":            {2, 40, 0},
+		"git version 2.41.0-rc1":                  {2, 41, 0},
+		"git version 1.9\n":                       {1, 9, 0},
+		"git version 2.43.0.vfs.0.0 (extra info)": {2, 43, 0},
+	} {
+		if v, err := ParseVersion(output); err != nil {
+			t.Fatal(err)
+		} else {
+			expectEq(t, expected, v)
+		}
+	}
+
+	for _, output := range []string{"", "git version", "version 2.39.5", "git version unknown"} {
+		if _, err := ParseVersion(output); err == nil {
+			t.Fatal("Expected an error parsing", output)
+		}
+	}
+}
+
+func TestGitVersionAtLeast(t *testing.T) {
+	v := GitVersion{2, 22, 1}
+	expectTrue(t, v.AtLeast(GitVersion{2, 22, 0}))
+	expectTrue(t, v.AtLeast(GitVersion{2, 22, 1}))
+	expectTrue(t, v.AtLeast(GitVersion{1, 30, 0}))
+	expectFalse(t, v.AtLeast(GitVersion{2, 22, 2}))
+	expectFalse(t, v.AtLeast(GitVersion{2, 23, 0}))
+	expectFalse(t, v.AtLeast(GitVersion{3, 0, 0}))
+	expectEq(t, "2.22.1", v.String())
+}
+
+func TestVersion(t *testing.T) {
+	runner := &ScriptedRunner{Commands: []ScriptedCommand{
+		{Args: []string{"version"}, Stdout: "git version 2.38.0\n"},
+	}}
+	defer useRunner(runner)()
+
+	// the version is only looked up once
+	for i := 0; i < 2; i++ {
+		if v, err := Version(); err != nil {
+			t.Fatal(err)
+		} else {
+			expectEq(t, GitVersion{2, 38, 0}, v)
+		}
+	}
+	if supported, err := Supports(CapabilityMergeTreeWriteTree); err != nil {
+		t.Fatal(err)
+	} else {
+		expectTrue(t, supported)
+	}
+	expectEq(t, 0, len(runner.Remaining()))
+}
+
+func TestUnsupportedGit(t *testing.T) {
+	runner := &ScriptedRunner{Commands: []ScriptedCommand{
+		{Args: []string{"version"}, Stdout: "git version 2.17.1\n"},
+		{Args: []string{"symbolic-ref", "--quiet", "--short", "HEAD"}, Stdout: "topic\n"},
+		{Args: []string{"symbolic-ref", "--quiet", "--short", "HEAD"}, ExitCode: 1},
+		{Args: []string{"reset", "--quiet", "HEAD", "--", "A"}},
+	}}
+	defer useRunner(runner)()
+
+	// falls back to symbolic-ref, which fails when HEAD is detached
+	if branch, err := GetCurrentBranchName(); err != nil {
+		t.Fatal(err)
+	} else if detached, err := GetCurrentBranchName(); err != nil {
+		t.Fatal(err)
+	} else if err := RestoreStaged("", "A"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, "topic", branch)
+		expectEq(t, "", detached)
+	}
+
+	var unsupported *UnsupportedError
+	if _, err := RangeDiff("A..B", "A..C"); !errors.As(err, &unsupported) {
+		t.Fatal("Expected an UnsupportedError, got", err)
+	} else {
+		expectEq(t, CapabilityRangeDiff, unsupported.Capability)
+		expectEq(t, GitVersion{2, 19, 0}, unsupported.Required)
+		expectEq(t, GitVersion{2, 17, 1}, unsupported.Version)
+		expectEq(t, "range-diff requires git 2.19.0 or later, but git is version 2.17.1", err.Error())
+	}
+	expectEq(t, 0, len(runner.Remaining()))
+}
+
+func TestIgnoreGlobalConfigFallback(t *testing.T) {
+	// a git too old for GIT_CONFIG_GLOBAL, which shows the HOME it's run with
+	oldGit := filepath.Join(t.TempDir(), "git")
+	script := "#!/bin/sh\nif [ \"$1\" = version ]; then echo git version 2.31.1; else echo \"$HOME\"; fi\n"
+	if err := os.WriteFile(oldGit, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer useRunner(&ExecRunner{GitPath: oldGit, Environment: Environment{IgnoreGlobalConfig: true}})()
+
+	if home, err := GitOutput("config", "--list"); err != nil {
+		t.Fatal(err)
+	} else {
+		expectEq(t, os.DevNull, home)
+	}
+}